@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// archiveFilename is where tasks archived out of filename are kept: beside
+// it, in the same format, as in gotodo.archive.json.
+func archiveFilename(filename string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + ".archive" + ext
+}
+
+// resolveArchiveAfter decides after how many days completed tasks are
+// archived on startup: the --archive-after flag, then $GOTODO_ARCHIVE_AFTER.
+// Zero means never.
+func resolveArchiveAfter(flagDays int) (int, error) {
+	if flagDays > 0 {
+		return flagDays, nil
+	}
+	env := os.Getenv("GOTODO_ARCHIVE_AFTER")
+	if env == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(env)
+	if err != nil {
+		return 0, fmt.Errorf(errorArchiveAfter, err)
+	}
+	return days, nil
+}
+
+// loadArchive loads the archived tasks, treating a missing archive as empty.
+func loadArchive(archive Store) ([]Task, error) {
+	tasks, err := archive.Load()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// moveTask copies task, sessions and all, into to and then removes it from
+// from. If it can't be removed, the copy is taken back out so the task isn't
+// left in both.
+func moveTask(task Task, from, to Store) error {
+	if err := to.ImportTask(task); err != nil {
+		return err
+	}
+	if err := from.DeleteTask(task.ID); err != nil {
+		to.DeleteTask(task.ID)
+		return err
+	}
+	return nil
+}
+
+// archiveTasks moves the tasks at the given indices to the archive. Undo
+// history is dropped, since it can't follow tasks into the archive file.
+func (m *model) archiveTasks(indices []int) {
+	moved := map[uuid.UUID]bool{}
+	for _, i := range indices {
+		if err := moveTask(m.tasks[i], m.store, m.archive); err != nil {
+			m.persist(err)
+			break
+		}
+		moved[m.tasks[i].ID] = true
+		m.archived = append([]Task{cloneTask(m.tasks[i])}, m.archived...)
+	}
+	m.tasks = slices.DeleteFunc(m.tasks, func(task Task) bool { return moved[task.ID] })
+	m.undoStack, m.redoStack = nil, nil
+}
+
+// completedSubtree returns the task at i and its subtasks if they are all
+// completed. Open tasks never go to the archive, where their timers couldn't
+// be stopped.
+func (m model) completedSubtree(i int) ([]int, bool) {
+	subtree := append([]int{i}, m.descendants(i)...)
+	if slices.ContainsFunc(subtree, func(j int) bool { return m.tasks[j].Status != Completed }) {
+		return nil, false
+	}
+	return subtree, true
+}
+
+// archiveSelected archives the selected task together with its subtasks,
+// once all of them are completed.
+func (m *model) archiveSelected() {
+	i, ok := m.selectedTask()
+	if !ok || m.store.ReadOnly() || m.archive.ReadOnly() {
+		return
+	}
+	if subtree, ok := m.completedSubtree(i); ok {
+		m.archiveTasks(subtree)
+	}
+}
+
+// autoArchive archives tasks completed more than days ago, along with their
+// subtasks once those are all completed too. days <= 0 turns it off.
+func (m *model) autoArchive(days int, now time.Time) {
+	if days <= 0 || m.store.ReadOnly() || m.archive.ReadOnly() {
+		return
+	}
+	cutoff := now.AddDate(0, 0, -days)
+	taken := map[int]bool{}
+	var indices []int
+	for i, task := range m.tasks {
+		completedAt, ok := task.completedAt()
+		if taken[i] || !ok || !completedAt.Before(cutoff) {
+			continue
+		}
+		subtree, ok := m.completedSubtree(i)
+		if !ok {
+			continue
+		}
+		for _, j := range subtree {
+			if !taken[j] {
+				taken[j] = true
+				indices = append(indices, j)
+			}
+		}
+	}
+	m.archiveTasks(indices)
+}
+
+// archivedSubtree returns the indices into m.archived of the task at i and
+// every archived task nested under it.
+func (m model) archivedSubtree(i int) []int {
+	found := []int{i}
+	for k := 0; k < len(found); k++ {
+		for j, task := range m.archived {
+			if task.ParentID == m.archived[found[k]].ID && !slices.Contains(found, j) {
+				found = append(found, j)
+			}
+		}
+	}
+	return found
+}
+
+// restoreSelectedArchived moves the archived task under the cursor, and its
+// archived subtasks, back into the task list.
+func (m *model) restoreSelectedArchived() {
+	if m.archiveCursor >= len(m.archived) || m.store.ReadOnly() || m.archive.ReadOnly() {
+		return
+	}
+	restored := map[uuid.UUID]bool{}
+	for _, j := range m.archivedSubtree(m.archiveCursor) {
+		task := m.archived[j]
+		if err := moveTask(task, m.archive, m.store); err != nil {
+			m.persist(err)
+			break
+		}
+		restored[task.ID] = true
+		m.tasks = append([]Task{cloneTask(task)}, m.tasks...)
+		m.ensureList(task.List)
+	}
+	m.archived = slices.DeleteFunc(m.archived, func(task Task) bool { return restored[task.ID] })
+	m.archiveCursor = min(m.archiveCursor, max(0, len(m.archived)-1))
+	m.undoStack, m.redoStack = nil, nil
+}
+
+// startArchiveView switches the list to the archived tasks.
+func (m *model) startArchiveView() {
+	m.mode = modeArchive
+	m.archiveCursor = 0
+	m.viewport.SetYOffset(0)
+	m.helpMsg = generateHelp(m.keyMap, modeArchive)
+	m.updateLayout()
+}
+
+// stopArchiveView goes back to the task list.
+func (m *model) stopArchiveView() {
+	m.mode = modeViewTasks
+	m.viewport.SetYOffset(0)
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+	m.updateLayout()
+}
+
+// renderArchiveView lists the archived tasks, most recently archived first.
+func (m model) renderArchiveView() string {
+	if len(m.archived) == 0 {
+		return " "
+	}
+	contentWidth := m.viewport.Width - taskViewportStyle.GetHorizontalFrameSize()
+	now := time.Now()
+	lines := make([]string, len(m.archived))
+	for i, task := range m.archived {
+		completed := "     "
+		if completedAt, ok := task.completedAt(); ok {
+			completed = monthDay(completedAt, m.useJalaliCalendar)
+		}
+		line := "  "
+		if i == m.archiveCursor {
+			line = "❯ "
+		}
+		line += completed + "  " + listDisplayName(task.List) + "  " + task.Description + "  [" + formatDuration(task.elapsed(now)) + "]"
+		style := listItemStyle
+		if i == m.archiveCursor {
+			style = selectedListItemStyle
+		}
+		lines[i] = style.Width(contentWidth).MaxHeight(1).Render(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newTestModel opens a model on an empty tasks file and archive.
+func newTestModel(t *testing.T) model {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "tasks.json")
+	store, err := openStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	archive, err := openStore(archiveFilename(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { archive.Close() })
+	return initialModel(store, archive, 0)
+}
+
+func TestCompletedSubtree(t *testing.T) {
+	parent, child := uuid.New(), uuid.New()
+	tests := []struct {
+		name     string
+		statuses [3]TaskStatus // parent, child, grandchild
+		want     []int
+		wantOK   bool
+	}{
+		{"all completed", [3]TaskStatus{Completed, Completed, Completed}, []int{0, 1, 2}, true},
+		{"open grandchild", [3]TaskStatus{Completed, Completed, Paused}, nil, false},
+		{"open parent", [3]TaskStatus{Pending, Completed, Completed}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			m.tasks = []Task{
+				{ID: parent, Description: "parent", Status: tt.statuses[0], CreatedAt: time.Now()},
+				{ID: child, Description: "child", Status: tt.statuses[1], ParentID: parent, CreatedAt: time.Now()},
+				{ID: uuid.New(), Description: "grandchild", Status: tt.statuses[2], ParentID: child, CreatedAt: time.Now()},
+			}
+			got, ok := m.completedSubtree(0)
+			slices.Sort(got)
+			if ok != tt.wantOK || !slices.Equal(got, tt.want) {
+				t.Errorf("completedSubtree(0) = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMoveTask(t *testing.T) {
+	start := time.Date(2025, 10, 13, 9, 0, 0, 0, time.UTC)
+	task := Task{ID: uuid.New(), Description: "a", Status: Completed, CreatedAt: start}
+	for i := range 50 {
+		session := Session{Start: start.Add(time.Duration(i) * time.Hour), End: start.Add(time.Duration(i)*time.Hour + time.Minute)}
+		task.Sessions = append(task.Sessions, session)
+	}
+	task.recalcTimeSpent()
+
+	for _, names := range [][2]string{{"tasks.json", "tasks.archive.json"}, {"tasks.db", "tasks.archive.json"}, {"tasks.json", "tasks.archive.db"}} {
+		t.Run(names[0]+" to "+names[1], func(t *testing.T) {
+			dir := t.TempDir()
+			from, err := openStore(filepath.Join(dir, names[0]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer from.Close()
+			to, err := openStore(filepath.Join(dir, names[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer to.Close()
+			for _, store := range []Store{from, to} {
+				if _, err := store.Load(); err != nil && !os.IsNotExist(err) {
+					t.Fatal(err)
+				}
+			}
+			if err := from.ImportTask(task); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := moveTask(task, from, to); err != nil {
+				t.Fatal(err)
+			}
+			left, _ := from.Load()
+			moved, _ := to.Load()
+			if len(left) != 0 || len(moved) != 1 {
+				t.Fatalf("%d tasks left and %d moved, want 0 and 1", len(left), len(moved))
+			}
+			if len(moved[0].Sessions) != len(task.Sessions) || moved[0].TimeSpent != task.TimeSpent {
+				t.Errorf("moved with %d sessions and %s tracked, want %d and %s", len(moved[0].Sessions), moved[0].TimeSpent, len(task.Sessions), task.TimeSpent)
+			}
+		})
+	}
+}
+
+// A task that can't be taken out of a read-only store isn't left in the
+// store it was being moved to as well.
+func TestMoveTaskFromReadOnlyStore(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tasks.json")
+	task := Task{ID: uuid.New(), Description: "a", Status: Completed, CreatedAt: time.Now()}
+	if err := saveTasksToFile(filename, tasksDocument{Tasks: []Task{task}}); err != nil {
+		t.Fatal(err)
+	}
+	lockedByOther(t, filename)
+	from, err := openStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer from.Close()
+	to, err := openStore(archiveFilename(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer to.Close()
+	for _, store := range []Store{from, to} {
+		if _, err := store.Load(); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+
+	if err := moveTask(task, from, to); err == nil {
+		t.Fatal("moved a task out of a read-only store")
+	}
+	if tasks, _ := to.Load(); len(tasks) != 0 {
+		t.Errorf("the copy was left behind: %d tasks", len(tasks))
+	}
+	if tasks, _ := from.Load(); len(tasks) != 1 {
+		t.Errorf("the original is gone: %d tasks", len(tasks))
+	}
+}
+
+func TestArchiveNeedsBothStoresWritable(t *testing.T) {
+	for _, locked := range []string{"tasks.json", "tasks.archive.json"} {
+		t.Run(locked, func(t *testing.T) {
+			dir := t.TempDir()
+			lockedByOther(t, filepath.Join(dir, locked))
+			store, err := openStore(filepath.Join(dir, "tasks.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			archive, err := openStore(filepath.Join(dir, "tasks.archive.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer archive.Close()
+			m := initialModel(store, archive, 0)
+			m.mode = modeViewTasks
+			m.tasks = []Task{{ID: uuid.New(), Description: "done", Status: Completed, CreatedAt: time.Now()}}
+			m.archived = []Task{{ID: uuid.New(), Description: "archived", Status: Completed, CreatedAt: time.Now()}}
+
+			m.archiveSelected()
+			m.restoreSelectedArchived()
+			if len(m.tasks) != 1 || len(m.archived) != 1 || m.tasks[0].Description != "done" {
+				t.Errorf("tasks moved with %s locked: %d in the list, %d archived", locked, len(m.tasks), len(m.archived))
+			}
+		})
+	}
+}
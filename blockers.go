@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// taskIndex returns the index of the task with the given ID, or -1.
+func taskIndex(tasks []Task, id uuid.UUID) int {
+	return slices.IndexFunc(tasks, func(task Task) bool { return task.ID == id })
+}
+
+// openBlockers returns the indices of the unfinished tasks the task at i is
+// blocked by. Blockers that were deleted no longer count.
+func openBlockers(tasks []Task, i int) []int {
+	var open []int
+	for _, id := range tasks[i].BlockedBy {
+		if j := taskIndex(tasks, id); j >= 0 && tasks[j].Status != Completed {
+			open = append(open, j)
+		}
+	}
+	return open
+}
+
+// isBlocked reports whether the task at i is waiting on an unfinished task.
+// Nothing is stored for this: a task unblocks as soon as its last blocker is
+// completed.
+func isBlocked(tasks []Task, i int) bool {
+	return tasks[i].Status != Completed && len(openBlockers(tasks, i)) > 0
+}
+
+// blockedError explains why the task at i can't be started.
+func blockedError(tasks []Task, i int) error {
+	var names []string
+	for _, j := range openBlockers(tasks, i) {
+		names = append(names, fmt.Sprintf("%q", tasks[j].Description))
+	}
+	return fmt.Errorf(errorBlocked, tasks[i].Description, strings.Join(names, ", "))
+}
+
+// dependsOn reports whether the task with ID from waits, directly or through
+// other tasks, on the task with ID on.
+func dependsOn(tasks []Task, from, on uuid.UUID) bool {
+	seen := map[uuid.UUID]bool{}
+	queue := []uuid.UUID{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == on {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if i := taskIndex(tasks, id); i >= 0 {
+			queue = append(queue, tasks[i].BlockedBy...)
+		}
+	}
+	return false
+}
+
+// startBlockerPick lets the user choose with the cursor a task that the
+// selected one is blocked by.
+func (m *model) startBlockerPick() {
+	i, ok := m.selectedTask()
+	if !ok {
+		return
+	}
+	m.blockTarget = m.tasks[i].ID
+	m.mode = modePickBlocker
+	m.helpMsg = generateHelp(m.keyMap, modePickBlocker)
+	m.updateLayout()
+}
+
+// finishBlockerPick records the task under the cursor as a blocker of the
+// one picked for, unless that would leave them waiting on each other. A
+// running task that becomes blocked is paused.
+func (m *model) finishBlockerPick(confirm bool) {
+	target := taskIndex(m.tasks, m.blockTarget)
+	if blocker, ok := m.selectedTask(); confirm && ok && target >= 0 {
+		blockerID := m.tasks[blocker].ID
+		switch {
+		case blocker == target || slices.Contains(m.tasks[target].BlockedBy, blockerID):
+		case dependsOn(m.tasks, blockerID, m.blockTarget):
+			m.err = fmt.Errorf(errorBlockerCycle, m.tasks[blocker].Description, m.tasks[target].Description)
+		default:
+			m.checkpoint()
+			m.tasks[target].BlockedBy = append(m.tasks[target].BlockedBy, blockerID)
+			if isBlocked(m.tasks, target) && m.tasks[target].Status == InProgress {
+				m.stopTask(target, time.Now(), Paused)
+			} else {
+				m.saveTask(target)
+			}
+		}
+	}
+	if target >= 0 {
+		m.selectTask(m.blockTarget)
+	}
+	m.blockTarget = uuid.Nil
+	m.mode = modeViewTasks
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+	m.updateLayout()
+}
+
+// clearBlockers removes every blocker from the selected task.
+func (m *model) clearBlockers() {
+	i, ok := m.selectedTask()
+	if !ok || len(m.tasks[i].BlockedBy) == 0 {
+		return
+	}
+	m.checkpoint()
+	m.tasks[i].BlockedBy = nil
+	m.saveTask(i)
+}
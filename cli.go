@@ -0,0 +1,602 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// command is a non-interactive subcommand run instead of the TUI.
+type command struct {
+	name  string
+	usage string
+	run   func(store, archive Store, args []string, out io.Writer) error
+}
+
+var commands = []command{
+	{"add", cmdAddUsage, runAddCommand},
+	{"list", cmdListUsage, runListCommand},
+	{"current", cmdCurrentUsage, runCurrentCommand},
+	{"totals", cmdTotalsUsage, runTotalsCommand},
+	{"report", cmdReportUsage, runReportCommand},
+	{"rate", cmdRateUsage, runRateCommand},
+	{"start", cmdStartUsage, runStartCommand},
+	{"pause", cmdPauseUsage, runPauseCommand},
+	{"done", cmdDoneUsage, runDoneCommand},
+	{"rm", cmdRmUsage, runRmCommand},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, usageHeader)
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\n", cmd.usage)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, usageFlags)
+	flag.CommandLine.SetOutput(w)
+	flag.PrintDefaults()
+}
+
+// runCommand runs a subcommand against store and returns the exit code.
+func runCommand(store, archive Store, args []string) int {
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, errorUnknownCommand, args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+	if err := cmd.run(store, archive, args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, errorCommand, cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+// loadForCommand loads all tasks, treating a missing file as empty.
+func loadForCommand(store Store) ([]Task, error) {
+	tasks, err := store.Load()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// resolveTaskArg finds a task by its 1-based position in `gotodo list` or by
+// a unique prefix of its ID. A number past the end of the list is taken as an
+// ID prefix, since short IDs can be all digits.
+func resolveTaskArg(tasks []Task, arg string) (int, error) {
+	if n, err := strconv.Atoi(arg); err == nil && n >= 1 && n <= len(tasks) {
+		return n - 1, nil
+	}
+	found := -1
+	for i, task := range tasks {
+		if strings.HasPrefix(task.ID.String(), strings.ToLower(arg)) {
+			if found >= 0 {
+				return 0, fmt.Errorf(errorAmbiguousTask, arg)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return 0, fmt.Errorf(errorNoSuchTask, arg)
+	}
+	return found, nil
+}
+
+// runningTask returns the index of the task whose timer is running.
+func runningTask(tasks []Task) (int, bool) {
+	for i, task := range tasks {
+		if task.Status == InProgress {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// stopStoredTask closes the task's running session, if any, and saves it
+// with the new status.
+func stopStoredTask(store Store, task *Task, now time.Time, status TaskStatus, note string) error {
+	if session, ok := task.stop(now); ok {
+		session.Note = note
+		task.Sessions[len(task.Sessions)-1].Note = note
+		if err := store.AppendSession(task.ID, session); err != nil {
+			return err
+		}
+	}
+	task.Status = status
+	return store.UpsertTask(*task)
+}
+
+func runAddCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	list := flags.String("list", "", flagListUsage)
+	priorityName := flags.String("priority", priorityNames[PriorityNone], flagPriorityUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	priority, err := parsePriority(*priorityName)
+	if err != nil {
+		return err
+	}
+	input, err := parseTaskInput(strings.Join(flags.Args(), " "), time.Now(), false)
+	if err != nil {
+		return err
+	}
+	description := strings.TrimSpace(input.Description)
+	if description == "" {
+		return errors.New(errorEmptyDescription)
+	}
+	if _, err := loadForCommand(store); err != nil {
+		return err
+	}
+
+	task := Task{ID: uuid.New(), Description: description, Status: Pending, CreatedAt: time.Now(), List: listNameFromInput(*list), Priority: priority, Due: input.Due, Tags: input.Tags, Recur: input.Recur}
+	if task.List != "" {
+		if err := store.AddList(task.List); err != nil {
+			return err
+		}
+	}
+	if err := store.UpsertTask(task); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, cmdAdded, shortID(task.ID), task.Description)
+	return nil
+}
+
+func runListCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	list := flags.String("list", "", flagListFilterUsage)
+	tag := flags.String("tag", "", flagTagFilterUsage)
+	output := addOutputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output)
+	if err != nil {
+		return err
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	records := []taskRecord{}
+	for i, task := range tasks {
+		if *list != "" && task.List != listNameFromInput(*list) {
+			continue
+		}
+		if *tag != "" && !task.hasTag(strings.ToLower(strings.TrimPrefix(*tag, "#"))) {
+			continue
+		}
+		records = append(records, newTaskRecord(i+1, task, now))
+	}
+
+	switch format {
+	case outputJSON:
+		return writeJSON(out, records)
+	case outputNDJSON:
+		return writeNDJSON(out, records)
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, listHeader)
+	for _, record := range records {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			record.Index, shortID(tasks[record.Index-1].ID), record.Status, formatDuration(time.Duration(record.TimeSpentSeconds)*time.Second),
+			record.List, record.Description,
+		)
+	}
+	return tw.Flush()
+}
+
+func runCurrentCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("current", flag.ContinueOnError)
+	output := addOutputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output)
+	if err != nil {
+		return err
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+
+	i, ok := runningTask(tasks)
+	if !ok {
+		switch format {
+		case outputJSON:
+			return writeJSON(out, nil)
+		case outputNDJSON:
+			return nil
+		}
+		fmt.Fprintln(out, cmdNothingRunning)
+		return nil
+	}
+
+	now := time.Now()
+	timer := timerRecord{
+		taskRecord:            newTaskRecord(i+1, tasks[i], now),
+		RunningSince:          tasks[i].LastStartedAt,
+		RunningSessionSeconds: int64(now.Sub(tasks[i].LastStartedAt).Seconds()),
+	}
+	switch format {
+	case outputJSON:
+		return writeJSON(out, timer)
+	case outputNDJSON:
+		return writeNDJSON(out, []timerRecord{timer})
+	}
+	fmt.Fprintf(out, cmdRunning,
+		shortID(tasks[i].ID), timer.Description, timer.RunningSince.Format("15:04"),
+		formatDuration(now.Sub(timer.RunningSince)), formatDuration(tasks[i].elapsed(now)),
+	)
+	return nil
+}
+
+func runTotalsCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("totals", flag.ContinueOnError)
+	by := flags.String("by", "list", flagTotalsByUsage)
+	output := addOutputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output)
+	if err != nil {
+		return err
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+	archived, err := loadArchive(archive)
+	if err != nil {
+		return err
+	}
+	tasks = append(tasks, archived...)
+
+	var groups []string
+	groupsOf := func(task Task) []string { return []string{listDisplayName(task.List)} }
+	switch *by {
+	case "list":
+		lists, err := store.Lists()
+		if err != nil {
+			return err
+		}
+		groups = append(groups, listDisplayName(""))
+		for _, name := range lists {
+			groups = append(groups, listDisplayName(name))
+		}
+	case "status":
+		for _, status := range []TaskStatus{Pending, InProgress, Paused, Completed} {
+			groups = append(groups, taskStatusNames[status])
+		}
+		groupsOf = func(task Task) []string { return []string{taskStatusNames[task.Status]} }
+	case "tag":
+		for _, task := range tasks {
+			for _, tag := range task.Tags {
+				groups = addTag(groups, tag)
+			}
+		}
+		slices.Sort(groups)
+		groupsOf = func(task Task) []string {
+			if len(task.Tags) == 0 {
+				return []string{untaggedGroup}
+			}
+			return task.Tags
+		}
+	default:
+		return fmt.Errorf(errorUnknownGroup, *by)
+	}
+
+	report := newTotalsReport(*by, groups, tasks, groupsOf, time.Now())
+	switch format {
+	case outputJSON:
+		return writeJSON(out, report)
+	case outputNDJSON:
+		return writeNDJSON(out, report.Groups)
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, totalsHeader, strings.ToUpper(*by))
+	report.Total.Group = totalsFooter
+	for _, row := range append(report.Groups, report.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n",
+			row.Group, row.Tasks, row.Completed, formatDuration(time.Duration(row.TimeSpentSeconds)*time.Second),
+		)
+	}
+	return tw.Flush()
+}
+
+func runReportCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	fromArg := flags.String("from", "", flagFromUsage)
+	toArg := flags.String("to", "today", flagToUsage)
+	formatArg := flags.String("format", string(timesheetCSV), flagSheetFormatUsage)
+	round := flags.Int("round", 0, flagRoundUsage)
+	billable := flags.Bool("billable", false, flagBillableUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseTimesheetFormat(*formatArg)
+	if err != nil {
+		return err
+	}
+	if *round < 0 {
+		return fmt.Errorf(errorNegativeRound, *round)
+	}
+	now := time.Now()
+	from := startOfWeek(now, false)
+	if *fromArg != "" {
+		day, ok := parsePastDay(strings.ToLower(*fromArg), now, false)
+		if !ok {
+			return fmt.Errorf(errorParseDate, *fromArg)
+		}
+		from = day
+	}
+	to, ok := parsePastDay(strings.ToLower(*toArg), now, false)
+	if !ok {
+		return fmt.Errorf(errorParseDate, *toArg)
+	}
+	if from.After(to) {
+		return fmt.Errorf(errorDateRange, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	}
+	if _, err := loadForCommand(store); err != nil {
+		return err
+	}
+	if _, err := loadArchive(archive); err != nil {
+		return err
+	}
+	query := TaskQuery{From: from, To: to.AddDate(0, 0, 1)}
+	tasks, err := store.Query(query)
+	if err != nil {
+		return err
+	}
+	archived, err := archive.Query(query)
+	if err != nil {
+		return err
+	}
+	rates, err := store.Rates()
+	if err != nil {
+		return err
+	}
+
+	sheet := newTimesheet(append(tasks, archived...), rates, query.From, query.To, time.Duration(*round)*time.Minute, *billable)
+	if format == timesheetMarkdown {
+		return sheet.writeMarkdown(out)
+	}
+	return sheet.writeCSV(out)
+}
+
+func runRateCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("rate", flag.ContinueOnError)
+	list := flags.String("list", "", flagRateListUsage)
+	tag := flags.String("tag", "", flagRateTagUsage)
+	taskArg := flags.String("task", "", flagRateTaskUsage)
+	clearRate := flags.Bool("clear", false, flagRateClearUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+	targets := 0
+	for _, target := range []string{*list, *tag, *taskArg} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets == 0 && !*clearRate && flags.NArg() == 0 {
+		return printRates(store, out)
+	}
+	if targets != 1 {
+		return errors.New(errorRateTarget)
+	}
+	var rate Rate
+	if !*clearRate {
+		if flags.NArg() == 0 {
+			return errors.New(errorNeedRate)
+		}
+		if rate, err = parseRate(strings.Join(flags.Args(), " ")); err != nil {
+			return err
+		}
+	}
+
+	var name string
+	switch {
+	case *list != "":
+		name = fmt.Sprintf("%s %q", rateKindList, listDisplayName(listNameFromInput(*list)))
+		err = store.SetListRate(listNameFromInput(*list), rate)
+	case *tag != "":
+		tagName := strings.ToLower(strings.TrimPrefix(*tag, "#"))
+		name = "#" + tagName
+		err = store.SetTagRate(tagName, rate)
+	default:
+		i, resolveErr := resolveTaskArg(tasks, *taskArg)
+		if resolveErr != nil {
+			return resolveErr
+		}
+		name = fmt.Sprintf("%q", tasks[i].Description)
+		tasks[i].Rate = rate
+		err = store.UpsertTask(tasks[i])
+	}
+	if err != nil {
+		return err
+	}
+	if *clearRate {
+		fmt.Fprintf(out, cmdRateCleared, name)
+	} else {
+		fmt.Fprintf(out, cmdRateSet, name, rate)
+	}
+	return nil
+}
+
+// printRates lists the rates set on lists and tags.
+func printRates(store Store, out io.Writer) error {
+	rates, err := store.Rates()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, rateHeader)
+	for _, name := range slices.Sorted(maps.Keys(rates.Lists)) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rateKindList, listDisplayName(name), rates.Lists[name])
+	}
+	for _, tag := range slices.Sorted(maps.Keys(rates.Tags)) {
+		fmt.Fprintf(tw, "%s\t#%s\t%s\n", rateKindTag, tag, rates.Tags[tag])
+	}
+	return tw.Flush()
+}
+
+func runStartCommand(store, archive Store, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(errorNeedTaskArg)
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+	i, err := resolveTaskArg(tasks, args[0])
+	if err != nil {
+		return err
+	}
+	if tasks[i].Status == InProgress {
+		fmt.Fprintf(out, cmdAlreadyRunning, tasks[i].Description)
+		return nil
+	}
+	if tasks[i].Status == Completed {
+		return fmt.Errorf(errorTaskCompleted, tasks[i].Description)
+	}
+	if isBlocked(tasks, i) {
+		return blockedError(tasks, i)
+	}
+
+	now := time.Now()
+	if running, ok := runningTask(tasks); ok {
+		if err := stopStoredTask(store, &tasks[running], now, Paused, ""); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, cmdPaused, tasks[running].Description, formatDuration(tasks[running].TimeSpent))
+	}
+	tasks[i].start(now)
+	// Timers started outside the TUI have no autosave stamp, so the TUI
+	// won't mistake them for ones left behind by a crash.
+	tasks[i].LastSavedAt = time.Time{}
+	if err := store.UpsertTask(tasks[i]); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, cmdStarted, tasks[i].Description)
+	return nil
+}
+
+func runPauseCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("pause", flag.ContinueOnError)
+	note := flags.String("note", "", flagNoteUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+	i, ok := runningTask(tasks)
+	if !ok {
+		fmt.Fprintln(out, cmdNothingRunning)
+		return nil
+	}
+	if err := stopStoredTask(store, &tasks[i], time.Now(), Paused, *note); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, cmdPaused, tasks[i].Description, formatDuration(tasks[i].TimeSpent))
+	return nil
+}
+
+func runDoneCommand(store, archive Store, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("done", flag.ContinueOnError)
+	note := flags.String("note", "", flagNoteUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+	var i int
+	if len(args) > 0 {
+		if i, err = resolveTaskArg(tasks, args[0]); err != nil {
+			return err
+		}
+	} else {
+		var ok bool
+		if i, ok = runningTask(tasks); !ok {
+			return errors.New(errorNeedTaskArg)
+		}
+	}
+	if tasks[i].Status == Completed {
+		return fmt.Errorf(errorTaskCompleted, tasks[i].Description)
+	}
+	now := time.Now()
+	next, repeats := tasks[i].nextRecurrence(now)
+	tasks[i].CompletedAt = now
+	if err := stopStoredTask(store, &tasks[i], now, Completed, *note); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, cmdCompleted, tasks[i].Description, formatDuration(tasks[i].TimeSpent))
+	if repeats {
+		if err := store.UpsertTask(next); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, cmdRecurred, shortID(next.ID), next.Description, next.Due.Format(time.DateOnly))
+	}
+	return nil
+}
+
+func runRmCommand(store, archive Store, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(errorNeedTaskArg)
+	}
+	tasks, err := loadForCommand(store)
+	if err != nil {
+		return err
+	}
+	i, err := resolveTaskArg(tasks, args[0])
+	if err != nil {
+		return err
+	}
+	// Subtasks go with their parent, as in the TUI.
+	for _, j := range append([]int{i}, descendantsOf(tasks, i)...) {
+		if err := store.DeleteTask(tasks[j].ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, cmdRemoved, tasks[j].Description)
+	}
+	return nil
+}
+
+// shortID is the abbreviated task ID shown by the CLI; any unique prefix is
+// accepted back.
+func shortID(id uuid.UUID) string {
+	return id.String()[:8]
+}
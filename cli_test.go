@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// openCommandStores opens an empty tasks file and its archive for running
+// commands against.
+func openCommandStores(t *testing.T) (Store, Store) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "tasks.json")
+	store, err := openStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	archive, err := openStore(archiveFilename(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { archive.Close() })
+	return store, archive
+}
+
+func TestReportCommandRange(t *testing.T) {
+	store, archive := openCommandStores(t)
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"-from", "mon"}, false},
+		{[]string{"-from", "-7d", "-to", "yesterday"}, true}, // not a date
+		{[]string{"-from", "today", "-to", "-1d"}, true},
+		{[]string{"-from", "-1d", "-to", "-1d"}, false},
+	}
+	for _, tt := range tests {
+		err := runReportCommand(store, archive, tt.args, io.Discard)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("report %q: error %v, want error %v", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveTaskArg(t *testing.T) {
+	tasks := []Task{
+		{ID: uuid.MustParse("12345678-0000-4000-8000-000000000000")},
+		{ID: uuid.MustParse("abcdef01-0000-4000-8000-000000000000")},
+		{ID: uuid.MustParse("abcdef02-0000-4000-8000-000000000000")},
+	}
+	tests := []struct {
+		arg     string
+		want    int
+		wantErr bool
+	}{
+		{"1", 0, false},
+		{"3", 2, false},
+		{"12", 0, false}, // past the end, so an ID prefix
+		{"1234", 0, false},
+		{"ABCDEF02", 2, false},
+		{"abcdef", 0, true}, // ambiguous
+		{"0", 0, true},
+		{"4", 0, true},
+		{"fff", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := resolveTaskArg(tasks, tt.arg)
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+			t.Errorf("resolveTaskArg(%q) = %d, %v; want %d, error %v", tt.arg, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRmCommandRemovesSubtasks(t *testing.T) {
+	store, archive := openCommandStores(t)
+	if _, err := store.Load(); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	parent := uuid.MustParse("aaaaaaaa-0000-4000-8000-000000000000")
+	child := uuid.MustParse("bbbbbbbb-0000-4000-8000-000000000000")
+	for _, task := range []Task{
+		{ID: uuid.New(), Description: "other", CreatedAt: time.Now()},
+		{ID: uuid.New(), Description: "grandchild", ParentID: child, CreatedAt: time.Now()},
+		{ID: child, Description: "child", ParentID: parent, CreatedAt: time.Now()},
+		{ID: parent, Description: "parent", CreatedAt: time.Now()},
+	} {
+		if err := store.UpsertTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runRmCommand(store, archive, []string{shortID(parent)}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "other" {
+		t.Errorf("left %d tasks, want only %q", len(tasks), "other")
+	}
+}
+
+func TestPauseCommandNote(t *testing.T) {
+	store, archive := openCommandStores(t)
+	if _, err := store.Load(); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	task := Task{ID: uuid.New(), Description: "running", Status: InProgress, CreatedAt: time.Now(), LastStartedAt: time.Now().Add(-time.Hour)}
+	if err := store.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runPauseCommand(store, archive, []string{"-note", "call with QA"}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := runStartCommand(store, archive, []string{"1"}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDoneCommand(store, archive, []string{"-note", "shipped", "1"}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || len(tasks[0].Sessions) != 2 {
+		t.Fatalf("got %d tasks, want 1 with 2 sessions", len(tasks))
+	}
+	for i, want := range []string{"call with QA", "shipped"} {
+		if got := tasks[0].Sessions[i].Note; got != want {
+			t.Errorf("session %d note = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jalaali/go-jalaali"
+)
+
+// Below detailSideMinWidth columns the detail pane goes under the list
+// instead of beside it, taking detailBottomHeight lines.
+const (
+	detailSideMinWidth = 110
+	detailBottomHeight = 12
+)
+
+// newNotesInput returns the text area notes are edited in.
+func newNotesInput() textarea.Model {
+	ta := textarea.New()
+	ta.ShowLineNumbers = false
+	ta.Prompt = ""
+	ta.Placeholder = notesPlaceholder
+	return ta
+}
+
+// startNotesInput opens the notes of the selected task for editing.
+func (m *model) startNotesInput() tea.Cmd {
+	i, ok := m.selectedTask()
+	if !ok {
+		return nil
+	}
+	m.mode = modeEditNotes
+	m.notes.SetValue(m.tasks[i].Notes)
+	m.helpMsg = generateHelp(m.keyMap, modeEditNotes)
+	m.updateLayout()
+	return m.notes.Focus()
+}
+
+// stopNotesInput closes the notes editor, saving the notes if save is set.
+func (m *model) stopNotesInput(save bool) {
+	notes := strings.TrimRight(m.notes.Value(), " \n")
+	if i, ok := m.selectedTask(); save && ok && notes != m.tasks[i].Notes {
+		m.checkpoint()
+		m.tasks[i].Notes = notes
+		m.saveTask(i)
+	}
+	m.mode = modeViewTasks
+	m.notes.Blur()
+	m.notes.Reset()
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+	m.updateLayout()
+}
+
+// renderNotesInput renders the notes editor in place of the task list.
+func (m model) renderNotesInput() string {
+	title := notesAreaTitle
+	if i, ok := m.selectedTask(); ok {
+		title = fmt.Sprintf(notesAreaTitleFor, m.tasks[i].Description)
+	}
+	boxTitle := lipgloss.NewStyle().Bold(true).Align(lipgloss.Center).Render(title)
+	return inputAreaStyle.Width(m.width - appHorizontalPadding).Render(lipgloss.JoinVertical(lipgloss.Top, boxTitle, m.notes.View()))
+}
+
+// detailShown reports whether the detail pane is open. The archive and the
+// report have no selected task to show.
+func (m model) detailShown() bool {
+	return m.showDetail && m.mode != modeArchive && m.mode != modeReport
+}
+
+// detailOnSide reports whether the detail pane fits beside the task list.
+func (m model) detailOnSide() bool {
+	return m.width-appHorizontalPadding >= detailSideMinWidth
+}
+
+// detailWidth is the width of the detail pane when it is beside the list.
+func (m model) detailWidth() int {
+	return (m.width - appHorizontalPadding) / 3
+}
+
+// renderDetailPane shows everything about the selected task, sized to sit
+// beside or under the task list.
+func (m model) renderDetailPane() string {
+	width, height := m.viewport.Width, detailBottomHeight
+	if m.detailOnSide() {
+		width, height = m.detailWidth(), m.viewport.Height
+	}
+	innerWidth := max(1, width-detailPaneStyle.GetHorizontalFrameSize())
+	innerHeight := max(1, height-detailPaneStyle.GetVerticalFrameSize())
+	content := lipgloss.NewStyle().Width(innerWidth).MaxHeight(innerHeight).Render(m.renderDetail())
+	return detailPaneStyle.Width(innerWidth + detailPaneStyle.GetHorizontalPadding()).Height(innerHeight).Render(content)
+}
+
+func (m model) renderDetail() string {
+	i, ok := m.selectedTask()
+	if !ok {
+		return detailLabelStyle.Render(detailNoTask)
+	}
+	task := m.tasks[i]
+	now := time.Now()
+	field := func(label, value string) string {
+		return detailLabelStyle.Render(label+":") + " " + value
+	}
+
+	status := task.Status.String()
+	if task.Status != InProgress && isBlocked(m.tasks, i) {
+		status = statusBlocked
+	}
+	lines := []string{
+		detailTitleStyle.Render(task.Description),
+		"",
+		field(detailStatus, status),
+		field(detailList, listDisplayName(task.List)),
+		field(detailTracked, formatDuration(task.elapsed(now))),
+		field(detailCreated, formatTimestamp(task.CreatedAt, m.useJalaliCalendar)),
+	}
+	if completedAt, ok := task.completedAt(); ok {
+		lines = append(lines, field(detailCompleted, formatTimestamp(completedAt, m.useJalaliCalendar)))
+	}
+	if task.Priority != PriorityNone {
+		lines = append(lines, field(detailPriority, priorityNames[task.Priority]))
+	}
+	if !task.Due.IsZero() {
+		lines = append(lines, field(detailDue, formatTimestamp(task.Due, m.useJalaliCalendar)))
+	}
+	if !task.Recur.IsZero() {
+		lines = append(lines, field(detailRepeats, recurKeyword+task.Recur.String()))
+	}
+	if len(task.Tags) > 0 {
+		lines = append(lines, field(detailTags, renderTagChips(task.Tags)))
+	}
+	if rate := m.rates.rateFor(task); !rate.IsZero() {
+		if rate.billable() {
+			lines = append(lines, field(detailRate, fmt.Sprintf(detailPerHour, rate)))
+			lines = append(lines, field(detailBillable, formatMoney(rate.bill(task.elapsed(now)), rate.Currency)))
+		} else {
+			lines = append(lines, field(detailRate, detailNotBillable))
+		}
+	}
+	if blockers := openBlockers(m.tasks, i); len(blockers) > 0 {
+		names := make([]string, len(blockers))
+		for k, j := range blockers {
+			names[k] = fmt.Sprintf("%q", m.tasks[j].Description)
+		}
+		lines = append(lines, field(detailBlockedBy, strings.Join(names, ", ")))
+	}
+
+	lines = append(lines, "", detailLabelStyle.Render(detailNotes))
+	if task.Notes == "" {
+		lines = append(lines, detailEmptyStyle.Render(detailNoNotes))
+	} else {
+		lines = append(lines, task.Notes)
+	}
+
+	lines = append(lines, "", detailLabelStyle.Render(fmt.Sprintf(detailSessions, len(task.Sessions))))
+	if task.Status == InProgress && !task.LastStartedAt.IsZero() {
+		lines = append(lines, m.renderSession(Session{Start: task.LastStartedAt}, now.Sub(task.LastStartedAt)))
+	}
+	for _, session := range slices.Backward(task.Sessions) {
+		lines = append(lines, m.renderSession(session, session.Duration()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderSession renders one line of the session list. A session without
+// an end is the one running.
+func (m model) renderSession(session Session, d time.Duration) string {
+	end := detailRunning
+	if !session.End.IsZero() {
+		end = session.End.Format("15:04")
+	}
+	line := fmt.Sprintf("%s %s–%s  %s", monthDay(session.Start, m.useJalaliCalendar), session.Start.Format("15:04"), end, formatDuration(d))
+	if session.Note != "" {
+		line += " " + detailEmptyStyle.Render(session.Note)
+	}
+	return line
+}
+
+// completedAt is when a completed task was finished. Tasks completed before
+// CompletedAt was recorded fall back to the end of their last session.
+func (t Task) completedAt() (time.Time, bool) {
+	switch {
+	case t.Status != Completed:
+		return time.Time{}, false
+	case !t.CompletedAt.IsZero():
+		return t.CompletedAt, true
+	case len(t.Sessions) > 0:
+		return t.Sessions[len(t.Sessions)-1].End, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// formatTimestamp formats t as a full date and time in the selected
+// calendar, leaving out the time for all-day dates.
+func formatTimestamp(t time.Time, jalali bool) string {
+	date := t.Format("2006-01-02")
+	if jalali {
+		jy, jm, jd, _ := jalaali.ToJalaali(t.Date())
+		date = fmt.Sprintf("%04d/%02d/%02d", jy, jm, jd)
+	}
+	if dueAllDay(t) {
+		return date
+	}
+	return date + " " + t.Format("15:04")
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jalaali/go-jalaali"
+)
+
+// dueKeyword introduces a due date in the add and edit input, as in
+// "Send invoice due:fri 17:00".
+const dueKeyword = "due:"
+
+// dueSoonWindow is how far ahead the stats bar looks for due tasks.
+const dueSoonWindow = 24 * time.Hour
+
+var (
+	clockPattern     = regexp.MustCompile(`^([01]?\d|2[0-3]):([0-5]\d)$`)
+	offsetPattern    = regexp.MustCompile(`^([+-])(\d+)([dw])$`)
+	isoDatePattern   = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	slashDatePattern = regexp.MustCompile(`^(?:(\d{4})/)?(\d{1,2})/(\d{1,2})$`)
+)
+
+// taskInput is what the user typed into the add or edit input, split into
+// its parts.
+type taskInput struct {
+	Description string
+	Due         time.Time
+	Tags        []string
+	Recur       Recurrence
+}
+
+// parseTaskInput pulls #tags, a due:<date> [HH:MM] phrase and an
+// every:<rule> repeat rule out of text. Slash dates are read in the Jalali
+// calendar when jalali is set, like the dates shown in the list. A repeating
+// task without a due date is due on the rule's first day.
+func parseTaskInput(text string, now time.Time, jalali bool) (taskInput, error) {
+	fields := strings.Fields(text)
+	var kept []string
+	var input taskInput
+	found := false
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if tag, ok := parseTag(field); ok {
+			input.Tags = addTag(input.Tags, tag)
+			found = true
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(field), recurKeyword) {
+			rule, err := parseRecurrence(field[len(recurKeyword):])
+			if err != nil {
+				return taskInput{}, err
+			}
+			input.Recur = rule
+			found = true
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(field), dueKeyword) {
+			kept = append(kept, field)
+			continue
+		}
+		phrase := field[len(dueKeyword):]
+		if i+1 < len(fields) && clockPattern.MatchString(fields[i+1]) {
+			phrase += " " + fields[i+1]
+			i++
+		}
+		due, err := parseDue(phrase, now, jalali)
+		if err != nil {
+			return taskInput{}, err
+		}
+		input.Due = due
+		found = true
+	}
+	if input.Due.IsZero() {
+		input.Due = input.Recur.firstDue(now)
+	}
+	input.Description = text
+	if found {
+		input.Description = strings.Join(kept, " ")
+	}
+	return input, nil
+}
+
+// parseDue understands "today", "tomorrow", weekday names ("fri" is the
+// coming Friday, or today on a Friday), offsets ("+3d", "+2w", "-1d"), ISO dates
+// and MM/DD or YYYY/MM/DD dates, each optionally followed by a time of day.
+// A due date without a time is due all day and is stored as local midnight.
+func parseDue(phrase string, now time.Time, jalali bool) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(phrase))
+	if len(fields) == 0 || len(fields) > 2 {
+		return time.Time{}, fmt.Errorf(errorParseDue, phrase)
+	}
+	datePart, clockPart := fields[0], ""
+	if len(fields) == 2 {
+		clockPart = fields[1]
+	} else if clockPattern.MatchString(datePart) {
+		datePart, clockPart = "today", datePart
+	}
+
+	day, ok := parseDueDay(datePart, now, jalali)
+	if !ok {
+		return time.Time{}, fmt.Errorf(errorParseDue, phrase)
+	}
+	if clockPart == "" {
+		return day, nil
+	}
+	match := clockPattern.FindStringSubmatch(clockPart)
+	if match == nil {
+		return time.Time{}, fmt.Errorf(errorParseDue, phrase)
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute), nil
+}
+
+// parseDueDay returns local midnight of the day datePart names. Weekday
+// names and dates without a year look ahead, as due dates do.
+func parseDueDay(datePart string, now time.Time, jalali bool) (time.Time, bool) {
+	return parseDay(datePart, now, jalali, false)
+}
+
+// parsePastDay is parseDueDay looking back, for the start or end of a date
+// range: "mon" is the most recent Monday, today included, and a date without
+// a year is the most recent one.
+func parsePastDay(datePart string, now time.Time, jalali bool) (time.Time, bool) {
+	return parseDay(datePart, now, jalali, true)
+}
+
+func parseDay(datePart string, now time.Time, jalali, past bool) (time.Time, bool) {
+	today := startOfDay(now)
+	// A date without a year is this year's unless that is on the wrong side
+	// of today.
+	yearStep, wrongSide := 1, today.After
+	if past {
+		yearStep, wrongSide = -1, today.Before
+	}
+	switch datePart {
+	case "today":
+		return today, true
+	case "tomorrow", "tmr":
+		return today.AddDate(0, 0, 1), true
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if datePart == name || datePart == name[:3] {
+			if past {
+				return today.AddDate(0, 0, -((int(today.Weekday()) - int(weekday) + 7) % 7)), true
+			}
+			return today.AddDate(0, 0, (int(weekday)-int(today.Weekday())+7)%7), true
+		}
+	}
+	if match := offsetPattern.FindStringSubmatch(datePart); match != nil {
+		n, err := strconv.Atoi(match[2])
+		if err != nil {
+			return time.Time{}, false
+		}
+		if match[3] == "w" {
+			n *= 7
+		}
+		if match[1] == "-" {
+			n = -n
+		}
+		return today.AddDate(0, 0, n), true
+	}
+	if match := isoDatePattern.FindStringSubmatch(datePart); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		day, _ := strconv.Atoi(match[3])
+		return gregorianDay(year, month, day, now.Location())
+	}
+	if match := slashDatePattern.FindStringSubmatch(datePart); match != nil {
+		month, _ := strconv.Atoi(match[2])
+		day, _ := strconv.Atoi(match[3])
+		year, yearGiven := 0, match[1] != ""
+		if yearGiven {
+			year, _ = strconv.Atoi(match[1])
+		}
+		if jalali {
+			if !yearGiven {
+				year, _, _, _ = jalaali.ToJalaali(today.Date())
+			}
+			date, ok := jalaliDay(year, month, day, now.Location())
+			if ok && !yearGiven && wrongSide(date) {
+				date, ok = jalaliDay(year+yearStep, month, day, now.Location())
+			}
+			return date, ok
+		}
+		if !yearGiven {
+			year = today.Year()
+		}
+		date, ok := gregorianDay(year, month, day, now.Location())
+		if ok && !yearGiven && wrongSide(date) {
+			date, ok = gregorianDay(year+yearStep, month, day, now.Location())
+		}
+		return date, ok
+	}
+	return time.Time{}, false
+}
+
+func gregorianDay(year, month, day int, loc *time.Location) (time.Time, bool) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, loc)
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, false // normalized, so it didn't exist
+	}
+	return date, true
+}
+
+func jalaliDay(year, month, day int, loc *time.Location) (time.Time, bool) {
+	if !jalaali.IsValidDate(year, month, day) {
+		return time.Time{}, false
+	}
+	gy, gm, gd, err := jalaali.ToGregorian(year, jalaali.Month(month), day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(gy, gm, gd, 0, 0, 0, 0, loc), true
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns local midnight of the first day of t's week, which is
+// Saturday in the Jalali calendar and Monday otherwise.
+func startOfWeek(t time.Time, jalali bool) time.Time {
+	first := time.Monday
+	if jalali {
+		first = time.Saturday
+	}
+	day := startOfDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) - int(first) + 7) % 7))
+}
+
+// dueAllDay reports whether a due date has no time of day.
+func dueAllDay(due time.Time) bool {
+	return due.Equal(startOfDay(due))
+}
+
+// monthDay formats t as MM/DD in the selected calendar.
+func monthDay(t time.Time, jalali bool) string {
+	if jalali {
+		_, jm, jd, _ := jalaali.ToJalaali(t.Date())
+		return fmt.Sprintf("%02d/%02d", jm, jd)
+	}
+	return fmt.Sprintf("%02d/%02d", t.Month(), t.Day())
+}
+
+// formatDueInput writes a due date back as a due: phrase that parseDue
+// reads the same way, for the edit input.
+func formatDueInput(due time.Time, jalali bool) string {
+	date := due.Format("2006-01-02")
+	if jalali {
+		jy, jm, jd, _ := jalaali.ToJalaali(due.Date())
+		date = fmt.Sprintf("%04d/%02d/%02d", jy, jm, jd)
+	}
+	if dueAllDay(due) {
+		return dueKeyword + date
+	}
+	return dueKeyword + date + " " + due.Format("15:04")
+}
+
+// dueState classifies a task's due date relative to now.
+type dueState int
+
+const (
+	dueNone dueState = iota
+	dueLater
+	dueToday
+	dueOverdue
+)
+
+func (t Task) dueState(now time.Time) dueState {
+	if t.Due.IsZero() || t.Status == Completed {
+		return dueNone
+	}
+	endOfDay := t.Due
+	if dueAllDay(t.Due) {
+		endOfDay = t.Due.AddDate(0, 0, 1)
+	}
+	switch {
+	case !now.Before(endOfDay):
+		return dueOverdue
+	case startOfDay(now).Equal(startOfDay(t.Due)):
+		return dueToday
+	default:
+		return dueLater
+	}
+}
+
+// dueSoon reports whether an open task is overdue or due within
+// dueSoonWindow.
+func (t Task) dueSoon(now time.Time) bool {
+	state := t.dueState(now)
+	return state == dueOverdue || state == dueToday || (state == dueLater && t.Due.Before(now.Add(dueSoonWindow)))
+}
+
+// renderDue returns the due column of a task row.
+func (m model) renderDue(task Task, now time.Time) string {
+	switch task.dueState(now) {
+	case dueOverdue:
+		return dueOverdueStyle.Render(dueOverdueMarker + " " + monthDay(task.Due, m.useJalaliCalendar))
+	case dueToday:
+		label := dueTodayLabel
+		if !dueAllDay(task.Due) {
+			label = task.Due.Format("15:04")
+		}
+		return dueTodayStyle.Render(dueTodayMarker + " " + label)
+	case dueLater:
+		return dueLaterStyle.Render(dueLaterMarker + " " + monthDay(task.Due, m.useJalaliCalendar))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// dueTestNow is Wednesday 2025-10-15 14:30, 1404/07/23 in the Jalali
+// calendar.
+var dueTestNow = time.Date(2025, 10, 15, 14, 30, 0, 0, time.UTC)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDueDay(t *testing.T) {
+	tests := []struct {
+		datePart string
+		jalali   bool
+		want     time.Time
+		wantOK   bool
+	}{
+		{"today", false, day(2025, 10, 15), true},
+		{"tomorrow", false, day(2025, 10, 16), true},
+		{"tmr", false, day(2025, 10, 16), true},
+		{"wed", false, day(2025, 10, 15), true},
+		{"friday", false, day(2025, 10, 17), true},
+		{"tue", false, day(2025, 10, 21), true},
+		{"+3d", false, day(2025, 10, 18), true},
+		{"+2w", false, day(2025, 10, 29), true},
+		{"-1d", false, day(2025, 10, 14), true},
+		{"2025-12-01", false, day(2025, 12, 1), true},
+		{"2025-02-30", false, time.Time{}, false},
+		{"12/25", false, day(2025, 12, 25), true},
+		{"10/15", false, day(2025, 10, 15), true},
+		{"01/05", false, day(2026, 1, 5), true}, // passed, so next year
+		{"2024/03/01", false, day(2024, 3, 1), true},
+		{"7/24", true, day(2025, 10, 16), true},
+		{"7/22", true, day(2026, 10, 14), true}, // passed, so next Jalali year
+		{"1404/1/1", true, day(2025, 3, 21), true},
+		{"1404/13/1", true, time.Time{}, false},
+		{"someday", false, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDueDay(tt.datePart, dueTestNow, tt.jalali)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("parseDueDay(%q, jalali=%v) = %v, %v; want %v, %v", tt.datePart, tt.jalali, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	tests := []struct {
+		phrase  string
+		want    time.Time
+		wantErr bool
+	}{
+		{"today", day(2025, 10, 15), false},
+		{"FRI 17:00", day(2025, 10, 17).Add(17 * time.Hour), false},
+		{"9:30", day(2025, 10, 15).Add(9*time.Hour + 30*time.Minute), false},
+		{"+1d 23:59", day(2025, 10, 16).Add(23*time.Hour + 59*time.Minute), false},
+		{"today 24:00", time.Time{}, true},
+		{"today noon", time.Time{}, true},
+		{"", time.Time{}, true},
+		{"fri 17:00 extra", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDue(tt.phrase, dueTestNow, false)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseDue(%q) = %v, %v; want %v, error %v", tt.phrase, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePastDay(t *testing.T) {
+	tests := []struct {
+		datePart string
+		jalali   bool
+		want     time.Time
+	}{
+		{"today", false, day(2025, 10, 15)},
+		{"wed", false, day(2025, 10, 15)},
+		{"mon", false, day(2025, 10, 13)},
+		{"thu", false, day(2025, 10, 9)},
+		{"-7d", false, day(2025, 10, 8)},
+		{"10/15", false, day(2025, 10, 15)},
+		{"06/01", false, day(2025, 6, 1)},
+		{"12/25", false, day(2024, 12, 25)}, // not yet this year
+		{"2026-01-05", false, day(2026, 1, 5)},
+		{"7/22", true, day(2025, 10, 14)},
+		{"7/24", true, day(2024, 10, 15)}, // not yet this Jalali year
+	}
+	for _, tt := range tests {
+		got, ok := parsePastDay(tt.datePart, dueTestNow, tt.jalali)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("parsePastDay(%q, jalali=%v) = %v, %v; want %v", tt.datePart, tt.jalali, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Keys of the filter terms that aren't plain words or tags.
+const (
+	filterStatusKey = "status:"
+	filterFromKey   = "from:"
+	filterToKey     = "to:"
+)
+
+// taskFilter narrows the task list to what was typed in the filter bar.
+// Words are fuzzy-matched against the description; status:NAME, #tag,
+// from:DATE and to:DATE narrow further. Terms of one kind are alternatives,
+// different kinds must all match.
+type taskFilter struct {
+	words    []string
+	statuses []TaskStatus
+	tags     []string
+	from, to time.Time // created on or after from, and before to
+	invalid  []string  // terms that could not be understood
+}
+
+// parseTaskFilter reads a filter query. Dates look back from today, so
+// "from:-7d" or "from:mon" work, and "to:" includes the whole day named.
+func parseTaskFilter(query string, now time.Time, jalali bool) taskFilter {
+	var filter taskFilter
+	for _, term := range strings.Fields(query) {
+		lower := strings.ToLower(term)
+		switch {
+		case strings.HasPrefix(lower, filterStatusKey):
+			statuses := matchStatuses(strings.TrimPrefix(lower, filterStatusKey))
+			if len(statuses) == 0 {
+				filter.invalid = append(filter.invalid, term)
+			}
+			filter.statuses = append(filter.statuses, statuses...)
+		case strings.HasPrefix(lower, "#") && len(lower) > 1:
+			filter.tags = append(filter.tags, lower[1:])
+		case strings.HasPrefix(lower, filterFromKey):
+			day, ok := parsePastDay(strings.TrimPrefix(lower, filterFromKey), now, jalali)
+			if !ok {
+				filter.invalid = append(filter.invalid, term)
+				continue
+			}
+			filter.from = day
+		case strings.HasPrefix(lower, filterToKey):
+			day, ok := parsePastDay(strings.TrimPrefix(lower, filterToKey), now, jalali)
+			if !ok {
+				filter.invalid = append(filter.invalid, term)
+				continue
+			}
+			filter.to = day.AddDate(0, 0, 1)
+		default:
+			filter.words = append(filter.words, lower)
+		}
+	}
+	return filter
+}
+
+// matchStatuses returns the statuses whose stored name starts with prefix,
+// so status:pa is enough for paused. Spaces and dashes stand for "_".
+func matchStatuses(prefix string) []TaskStatus {
+	prefix = strings.NewReplacer("-", "_", " ", "_").Replace(prefix)
+	if prefix == "" {
+		return nil
+	}
+	var statuses []TaskStatus
+	for _, status := range []TaskStatus{Pending, InProgress, Paused, Completed} {
+		if strings.HasPrefix(taskStatusNames[status], prefix) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+func (f taskFilter) matches(task Task) bool {
+	if len(f.statuses) > 0 && !slices.Contains(f.statuses, task.Status) {
+		return false
+	}
+	if len(f.tags) > 0 && !slices.ContainsFunc(f.tags, func(prefix string) bool {
+		return slices.ContainsFunc(task.Tags, func(tag string) bool { return strings.HasPrefix(tag, prefix) })
+	}) {
+		return false
+	}
+	if !f.from.IsZero() && task.CreatedAt.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && !task.CreatedAt.Before(f.to) {
+		return false
+	}
+	for _, word := range f.words {
+		if !fuzzyMatch(word, task.Description) {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyMatch reports whether the letters of pattern appear in text in
+// order, ignoring case: "lgnbg" matches "Fix login bug".
+func fuzzyMatch(pattern, text string) bool {
+	remaining := []rune(pattern)
+	for _, r := range strings.ToLower(text) {
+		if len(remaining) == 0 {
+			break
+		}
+		if unicode.ToLower(remaining[0]) == r {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+// startFilter opens the filter bar, keeping the current query for editing.
+func (m *model) startFilter() tea.Cmd {
+	m.mode = modeFilter
+	m.input.SetValue(m.filterQuery)
+	m.input.CursorEnd()
+	m.input.Placeholder = filterPlaceholder
+	m.input.Focus()
+	m.helpMsg = generateHelp(m.keyMap, modeFilter)
+	m.updateLayout()
+	return textinput.Blink
+}
+
+// applyFilter narrows the list to query as it is typed.
+func (m *model) applyFilter(query string) {
+	if query == m.filterQuery {
+		return
+	}
+	m.filterQuery = query
+	m.filter = parseTaskFilter(query, time.Now(), m.useJalaliCalendar)
+	m.cursor = 0
+	m.viewport.SetYOffset(0)
+}
+
+// stopFilter closes the filter bar. The filter stays in effect unless clear
+// is set.
+func (m *model) stopFilter(clear bool) {
+	if clear {
+		m.applyFilter("")
+	} else if len(m.filter.invalid) > 0 {
+		m.err = fmt.Errorf(errorFilterTerms, strings.Join(m.filter.invalid, " "))
+	}
+	m.mode = modeViewTasks
+	m.input.Blur()
+	m.input.SetValue("")
+	m.input.Placeholder = inputPlaceholder
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+	m.updateLayout()
+}
+
+// renderFilterBar shows the filter being typed, or the one in effect.
+func (m model) renderFilterBar() string {
+	if m.mode == modeFilter {
+		return filterBarStyle.Render(filterPrompt + " " + m.input.View())
+	}
+	return filterBarStyle.Render(fmt.Sprintf(filterActive, m.filterQuery, len(m.visibleTasks())))
+}
+
+// showFilterBar reports whether the filter bar takes a line above the list.
+func (m model) showFilterBar() bool {
+	return m.mode == modeFilter || m.filterQuery != ""
+}
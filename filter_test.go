@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTaskFilterDates(t *testing.T) {
+	tests := []struct {
+		query    string
+		from, to time.Time
+	}{
+		{"from:mon", day(2025, 10, 13), time.Time{}},
+		{"from:-7d to:today", day(2025, 10, 8), day(2025, 10, 16)},
+		{"to:12/25", time.Time{}, day(2024, 12, 26)},
+		{"from:06/01 to:fri", day(2025, 6, 1), day(2025, 10, 11)},
+	}
+	for _, tt := range tests {
+		filter := parseTaskFilter(tt.query, dueTestNow, false)
+		if len(filter.invalid) > 0 || !filter.from.Equal(tt.from) || !filter.to.Equal(tt.to) {
+			t.Errorf("parseTaskFilter(%q) = from %v, to %v, invalid %q; want from %v, to %v", tt.query, filter.from, filter.to, filter.invalid, tt.from, tt.to)
+		}
+	}
+
+	created := Task{Description: "a", CreatedAt: day(2025, 10, 14).Add(9 * time.Hour)}
+	if !parseTaskFilter("from:mon", dueTestNow, false).matches(created) {
+		t.Error("from:mon hides a task created on Tuesday")
+	}
+	if parseTaskFilter("to:mon", dueTestNow, false).matches(created) {
+		t.Error("to:mon shows a task created on Tuesday")
+	}
+}
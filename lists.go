@@ -0,0 +1,145 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+)
+
+// listDisplayName returns how a list is labelled in the UI; the default list
+// is stored as "".
+func listDisplayName(name string) string {
+	if name == "" {
+		return defaultListName
+	}
+	return name
+}
+
+// listNameFromInput maps what the user typed to a stored list name.
+func listNameFromInput(value string) string {
+	name := strings.TrimSpace(value)
+	if strings.EqualFold(name, defaultListName) {
+		return ""
+	}
+	return name
+}
+
+// loadLists collects the stored lists plus any list only referenced by a
+// task, with the default list first.
+func (m *model) loadLists() error {
+	stored, err := m.store.Lists()
+	m.lists = []string{""}
+	for _, name := range stored {
+		if !slices.Contains(m.lists, name) {
+			m.lists = append(m.lists, name)
+		}
+	}
+	for _, task := range m.tasks {
+		if !slices.Contains(m.lists, task.List) {
+			m.lists = append(m.lists, task.List)
+		}
+	}
+	return err
+}
+
+func (m model) currentList() string {
+	return m.lists[m.listIndex]
+}
+
+// visibleTasks returns the indices into m.tasks of the tasks shown in the
+// current list, in display order. The cursor indexes into this slice.
+func (m model) visibleTasks() []int {
+	rows := m.visibleRows()
+	visible := make([]int, len(rows))
+	for i, row := range rows {
+		visible[i] = row.index
+	}
+	return visible
+}
+
+// selectedTask returns the index into m.tasks of the task under the cursor.
+func (m model) selectedTask() (int, bool) {
+	visible := m.visibleTasks()
+	if m.cursor < 0 || m.cursor >= len(visible) {
+		return 0, false
+	}
+	return visible[m.cursor], true
+}
+
+// selectTask moves the cursor to the task with the given ID, if it is shown.
+func (m *model) selectTask(id uuid.UUID) {
+	for cursor, i := range m.visibleTasks() {
+		if m.tasks[i].ID == id {
+			m.cursor = cursor
+			m.ensureCursorVisible()
+			return
+		}
+	}
+}
+
+func (m *model) switchList(delta int) {
+	m.listIndex = (m.listIndex + delta + len(m.lists)) % len(m.lists)
+	m.tagFilter = ""
+	m.cursor = 0
+	m.viewport.SetYOffset(0)
+}
+
+// ensureList makes sure a list exists, creating and storing it if needed,
+// and returns its index.
+func (m *model) ensureList(name string) int {
+	if i := slices.Index(m.lists, name); i >= 0 {
+		return i
+	}
+	m.lists = append(m.lists, name)
+	m.persist(m.store.AddList(name))
+	return len(m.lists) - 1
+}
+
+// moveSelectedTask moves the task under the cursor, with its subtasks, to
+// the named list. A subtask moved on its own leaves its parent.
+func (m *model) moveSelectedTask(name string) {
+	i, ok := m.selectedTask()
+	if !ok {
+		return
+	}
+	m.ensureList(name)
+	if m.tasks[i].List == name {
+		return
+	}
+	m.checkpoint()
+	m.tasks[i].ParentID = uuid.Nil
+	m.tasks[i].Position = 0 // top of the new list's manual order
+	for _, j := range append(m.descendants(i), i) {
+		m.tasks[j].List = name
+		m.saveTask(j)
+	}
+}
+
+func (m model) renderListTabs() string {
+	tabs := make([]string, len(m.lists))
+	for i, name := range m.lists {
+		style := listTabStyle
+		if i == m.listIndex {
+			style = activeListTabStyle
+		}
+		tabs[i] = style.Render(listDisplayName(name))
+	}
+	return listTabBarStyle.Render(lipgloss.JoinHorizontal(lipgloss.Top, tabs...))
+}
+
+// listTimeSpent totals the tracked time of every task shown in the current
+// list, counting the subtasks of collapsed tasks too.
+func (m model) listTimeSpent(now time.Time) time.Duration {
+	var total time.Duration
+	for _, i := range m.visibleTasks() {
+		if m.collapsed[m.tasks[i].ID] {
+			total += m.rolledUpTime(i, now)
+		} else {
+			total += m.tasks[i].elapsed(now)
+		}
+	}
+	return total
+}
@@ -2,14 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -22,16 +23,18 @@ import (
 const (
 	title                 = "Go Todo TUI - Time Tracker"
 	newTaskPrompt         = "New Task:"
-	inputPlaceholder      = "Describe your task..."
+	inputPlaceholder      = "Describe your task... (#tag due:fri 17:00 every:mon)"
 	noTasks               = "No tasks yet. Press 'a' to add one!"
 	statusPending         = "⏳ Pending"
 	statusInProgress      = "▶️ In Progress"
 	statusPaused          = "⏸️ Paused"
 	statusCompleted       = "✅ Completed"
+	statusBlocked         = "⛔ Blocked"
 	helpAdd               = "add task"
 	helpDelete            = "delete task"
 	helpToggle            = "start/pause/resume"
 	helpComplete          = "complete task"
+	helpReopen            = "reopen task"
 	helpNav               = "nav"
 	helpQuit              = "quit"
 	helpConfirm           = "confirm"
@@ -41,6 +44,38 @@ const (
 	helpConfirmStay       = "confirm (stay)"
 	helpToggleLineNumbers = "toggle line #s"
 	helpToggleCalendar    = "toggle calendar (G/J)"
+	helpEdit              = "edit task"
+	helpSave              = "save"
+	helpUndo              = "undo"
+	helpRedo              = "redo"
+	helpSwitchList        = "switch list"
+	helpNewList           = "new list"
+	helpMoveTask          = "move to list"
+	helpPriority          = "raise/lower priority"
+	helpSort              = "cycle sort"
+	helpMoveInOrder       = "move (manual sort)"
+	helpAddSubtask        = "add subtask"
+	helpCollapse          = "collapse/expand"
+	helpFilterTag         = "filter by tag"
+	helpFilter            = "filter"
+	helpPickBlocker       = "set blocker"
+	helpClearBlockers     = "clear blockers"
+	helpChooseBlocker     = "choose the blocking task"
+	helpDetail            = "toggle details"
+	helpNotes             = "edit notes"
+	helpNewline           = "new line"
+	helpArchive           = "archive completed task"
+	helpArchiveView       = "archive"
+	helpRestore           = "restore"
+	helpReport            = "weekly report"
+	helpWeek              = "previous/next week"
+	helpScroll            = "scroll"
+	helpRate              = "set hourly rate"
+	helpApply             = "apply"
+	helpClearFilter       = "clear filter"
+	helpRecoverKeep       = "keep elapsed time"
+	helpRecoverEnd        = "end at last autosave"
+	helpRecoverDiscard    = "discard"
 	savingTasks           = "Saving tasks..."
 	bye                   = "Bye!"
 	errorOnExit           = "Error on exit: %v\n"
@@ -53,20 +88,182 @@ const (
 	errorUnmarshalTasks   = "unmarshal tasks: %w"
 	errorRunningProgram   = "Error running program: %v\n"
 	errorLoadingTasksLog  = "Error loading tasks: %v\n"
+	errorOpeningTasksLog  = "Error opening tasks file: %v\n"
+	errorTaskNotFound     = "task %s not found"
+	errorOpenDatabase     = "open database: %w"
+	errorMigrateDatabase  = "migrate database: %w"
+	errorQueryDatabase    = "query database: %w"
+	errorWriteDatabase    = "write database: %w"
+	errorUnknownStatus    = "unknown task status %v"
+	errorUnknownPriority  = "unknown priority %v"
+	errorFilterTerms      = "filter terms not understood: %s"
+	errorBlocked          = "%q is blocked by %s"
+	errorBlockerCycle     = "%q already waits on %q"
+	errorParseRecur       = "cannot understand repeat rule %q (try daily, weekdays, fri, 15th or 3d)"
+	errorParseRate        = "cannot understand rate %q (try 95, 95.50 EUR or none)"
+	errorParseDue         = "cannot understand due date %q (try today, tomorrow, fri 17:00, +3d or 2025-06-30)"
+	errorMalformedTasks   = "malformed tasks file"
+	errorNewerSchema      = "tasks file uses schema version %d, which is newer than this Gotodo supports"
+	errorMigrateSchema    = "migrate schema v%d to v%d: %w"
+	errorBackupTasks      = "back up tasks file: %w"
+	errorHomeDir          = "find home directory: %w"
+	errorCreateDataDir    = "create data directory: %w"
+	flagFileUsage         = "tasks file to use (.json, or .db for SQLite); overrides $GOTODO_FILE"
+	flagArchiveAfterUsage = "archive tasks completed more than this many days ago on startup; overrides $GOTODO_ARCHIVE_AFTER (default: never)"
+	errorArchiveAfter     = "GOTODO_ARCHIVE_AFTER must be a number of days: %w"
+	errorCreateLock       = "create lock file: %w"
+	errorFileLocked       = "tasks file is locked by another Gotodo instance"
+	errorReadOnly         = "tasks file is open read-only because another Gotodo instance holds the lock"
+	errorExternalChange   = "tasks file was changed by another program; restart Gotodo to reload it"
+	errorNotLoaded        = "tasks file is left untouched because it could not be loaded: %w"
+	readOnlyIndicator     = "🔒 Read-only"
+	sortIndicator         = "Sort: "
+	sortCreatedLabel      = "created"
+	sortStatusLabel       = "status"
+	sortTimeSpentLabel    = "time spent"
+	sortPriorityLabel     = "priority"
+	sortDueLabel          = "due date"
+	sortAlphabeticalLabel = "alphabetical"
+	sortManualLabel       = "manual"
+	tagFilterIndicator    = "Tag: #"
+	filterPrompt          = "/"
+	filterPlaceholder     = "words, status:paused, #tag, from:-7d, to:today"
+	filterActive          = "Filter: %s (%d shown) — / to change, esc to clear"
+	priorityLowMarker     = "↓"
+	priorityMediumMarker  = "!"
+	priorityHighMarker    = "!!"
+	priorityUrgentMarker  = "!!!"
+	dueOverdueMarker      = "❗"
+	dueTodayMarker        = "⏰"
+	dueLaterMarker        = "📅"
+	dueTodayLabel         = "today"
+	collapsedMarker       = "▸ "
+	expandedMarker        = "▾ "
+	completionFormat      = "%d%%"
+	recurMarker           = "🔁 "
+	notesAreaTitle        = "🗒️ Notes"
+	notesAreaTitleFor     = "🗒️ Notes for %q"
+	notesPlaceholder      = "Context, links, acceptance criteria..."
+	detailNoTask          = "No task selected"
+	detailStatus          = "Status"
+	detailList            = "List"
+	detailTracked         = "Tracked"
+	detailCreated         = "Created"
+	detailCompleted       = "Completed"
+	detailPriority        = "Priority"
+	detailDue             = "Due"
+	detailRepeats         = "Repeats"
+	detailTags            = "Tags"
+	detailBlockedBy       = "Blocked by"
+	detailRate            = "Rate"
+	detailBillable        = "Billable"
+	detailPerHour         = "%s/h"
+	detailNotBillable     = "not billable"
+	detailNotes           = "Notes"
+	detailNoNotes         = "No notes yet. Press N to add some."
+	detailSessions        = "Sessions (%d)"
+	detailRunning         = "now"
+	archiveIndicator      = "🗄️ Archive (%d)"
+	archiveEmpty          = "The archive is empty. Press 'x' on a completed task to archive it."
+	reportIndicator       = "📊 Week of %s – %s"
+	reportTasks           = "Task"
+	reportTags            = "Tag"
+	reportTotal           = "Total"
+	reportNoTime          = "-"
+	reportNothing         = "No time tracked this week."
+	reportAmount          = "Amount"
 	inputAreaTitle        = "📝 Add New Task"
+	subtaskAreaTitle      = "📝 Add Subtask to %q"
+	editTaskPrompt        = "Edit Task:"
+	editAreaTitle         = "✏️ Edit Task"
+	newListPrompt         = "List Name:"
+	newListAreaTitle      = "🗂️ New List"
+	moveTaskPrompt        = "Move To:"
+	moveTaskAreaTitle     = "📦 Move Task to List"
+	listPlaceholder       = "List name (tab completes)..."
+	ratePrompt            = "Rate:"
+	rateAreaTitle         = "💰 Hourly Rate for %q"
+	ratePlaceholder       = "95 EUR, none to bill nothing, or empty for the tag or list rate"
+	defaultListName       = "Inbox"
+	recoverAreaTitle      = "⚠️ Recover Running Timer"
+	recoverPrompt         = "%q was still running when Gotodo last exited.\nStarted: %s\nLast autosave: %s (%s tracked since start)"
+	recoverNeverSaved     = "never"
 	statsPending          = "Pending"
 	statsInProgress       = "In Progress"
 	statsCompleted        = "Completed"
+	statsCompletedRecent  = "%d today, %d this week"
+	statsDueSoon          = "Due soon"
+	statsTracked          = "Tracked"
 	calendarGregorian     = "Gregorian (MM/DD)"
 	calendarJalali        = "Jalali (MM/DD)"
+	sessionMigratedNote   = "migrated from accumulated time"
 )
 
-var tasksFilename string
-
-func init() {
-	homeDir, _ := os.UserHomeDir()
-	tasksFilename = path.Join(homeDir, ".config", "gotodo.json")
-}
+// English CLI Strings
+const (
+	usageHeader           = "Usage: gotodo [flags] [command]\n\nWithout a command, opens the interactive task tracker.\n\nCommands:"
+	usageFlags            = "\nFORMAT is table (the default), json or ndjson. GROUP is list (the default), status or tag.\nTEXT may contain #tags and a due:DATE.\n\nFlags:"
+	cmdAddUsage           = "add [-list NAME] [-priority P] TEXT\tadd a pending task"
+	cmdListUsage          = "list [-list NAME] [-tag TAG] [-output FORMAT]\tlist tasks with their index and ID"
+	cmdCurrentUsage       = "current [-output FORMAT]\tshow the running timer"
+	cmdTotalsUsage        = "totals [-by GROUP] [-output FORMAT]\ttotal tracked time per list, status or tag"
+	cmdStartUsage         = "start INDEX|ID\tstart a task's timer, pausing the running one"
+	cmdPauseUsage         = "pause [-note TEXT]\tpause the running timer"
+	cmdDoneUsage          = "done [-note TEXT] [INDEX|ID]\tcomplete a task (default: the running one)"
+	cmdRmUsage            = "rm INDEX|ID\tdelete a task and its subtasks"
+	cmdReportUsage        = "report [-from DATE] [-to DATE] [-format csv|md] [-round MIN] [-billable]\ttimesheet of tracked sessions (default: this week)"
+	cmdRateUsage          = "rate [-list NAME|-tag TAG|-task INDEX|ID] [RATE|-clear]\tshow hourly rates, or set one such as 95 EUR or none"
+	flagListUsage         = "list to add the task to"
+	flagPriorityUsage     = "priority: none, low, medium, high or urgent"
+	flagListFilterUsage   = "only show tasks in this list"
+	flagOutputUsage       = "output format: table, json or ndjson"
+	flagTotalsByUsage     = "group totals by list, status or tag"
+	flagTagFilterUsage    = "only show tasks with this tag"
+	flagFromUsage         = "first day of the timesheet, such as mon, -7d or 2025-06-01 (default: start of this week)"
+	flagToUsage           = "last day of the timesheet (default: today)"
+	flagSheetFormatUsage  = "timesheet format: csv or md"
+	flagRoundUsage        = "round each session to the nearest this many minutes, such as 6 or 15 (default: no rounding)"
+	flagBillableUsage     = "leave out tasks that aren't billed"
+	flagRateListUsage     = "list to set the rate of"
+	flagRateTagUsage      = "tag to set the rate of"
+	flagRateTaskUsage     = "task to set the rate of, by index or ID"
+	flagRateClearUsage    = "remove the rate, so the tag's or list's applies"
+	flagNoteUsage         = "note to keep on the session this closes"
+	rateHeader            = "KIND\tNAME\tRATE"
+	rateKindList          = "list"
+	rateKindTag           = "tag"
+	cmdRateSet            = "Set the rate of %s to %s\n"
+	cmdRateCleared        = "Cleared the rate of %s\n"
+	timesheetHeader       = "Date,Start,End,Duration,Task,Tags,Amount"
+	listHeader            = "#\tID\tSTATUS\tTIME\tLIST\tDESCRIPTION"
+	totalsHeader          = "%s\tTASKS\tCOMPLETED\tTIME\n"
+	totalsFooter          = "TOTAL"
+	cmdRunning            = "%s %q running since %s (%s this session, %s total)\n"
+	cmdAdded              = "Added %s %q\n"
+	cmdStarted            = "Started %q\n"
+	cmdPaused             = "Paused %q (%s tracked)\n"
+	cmdCompleted          = "Completed %q (%s tracked)\n"
+	cmdRecurred           = "Next %s %q is due %s\n"
+	cmdRemoved            = "Removed %q\n"
+	cmdAlreadyRunning     = "%q is already running\n"
+	cmdNothingRunning     = "No timer is running."
+	errorUnknownCommand   = "Unknown command %q\n\n"
+	errorCommand          = "gotodo %s: %v\n"
+	errorNoSuchTask       = "no task matches %q"
+	errorAmbiguousTask    = "%q matches more than one task ID"
+	errorNeedTaskArg      = "expected a task index or ID"
+	errorEmptyDescription = "task description is empty"
+	errorTaskCompleted    = "%q is already completed"
+	errorUnknownOutput    = "unknown output format %q (want table, json or ndjson)"
+	errorUnknownGroup     = "cannot group totals by %q (want list, status or tag)"
+	errorUnknownSheet     = "unknown timesheet format %q (want csv or md)"
+	errorParseDate        = "cannot understand date %q (try today, mon, -7d or 2025-06-30)"
+	errorDateRange        = "-from %s is after -to %s"
+	errorNegativeRound    = "cannot round to %d minutes"
+	errorRateTarget       = "set a rate for exactly one of -list, -tag or -task"
+	errorNeedRate         = "expected a rate such as 95 EUR or none, or -clear"
+	untaggedGroup         = "(untagged)"
+)
 
 type TaskStatus int
 
@@ -92,13 +289,124 @@ func (s TaskStatus) String() string {
 	}
 }
 
+// taskStatusNames are the stable names statuses are stored under. Never
+// rename one; add a schema migration instead.
+var taskStatusNames = map[TaskStatus]string{
+	Pending:    "pending",
+	InProgress: "in_progress",
+	Paused:     "paused",
+	Completed:  "completed",
+}
+
+func parseTaskStatus(name string) (TaskStatus, error) {
+	for status, statusName := range taskStatusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf(errorUnknownStatus, name)
+}
+
+func (s TaskStatus) MarshalText() ([]byte, error) {
+	name, ok := taskStatusNames[s]
+	if !ok {
+		return nil, fmt.Errorf(errorUnknownStatus, int(s))
+	}
+	return []byte(name), nil
+}
+
+func (s *TaskStatus) UnmarshalText(text []byte) error {
+	status, err := parseTaskStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// Session is one continuous stretch of work on a task, from start to pause.
+type Session struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Note  string    `json:"note,omitempty"`
+}
+
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
 type Task struct {
 	ID            uuid.UUID     `json:"id"`
 	Description   string        `json:"description"`
 	Status        TaskStatus    `json:"status"`
-	TimeSpent     time.Duration `json:"time_spent"`
+	TimeSpent     time.Duration `json:"time_spent"` // derived from Sessions
 	LastStartedAt time.Time     `json:"last_started_at"`
 	CreatedAt     time.Time     `json:"created_at"`
+	Sessions      []Session     `json:"sessions"`
+	LastSavedAt   time.Time     `json:"last_saved_at,omitzero"` // last TUI autosave while running, for crash recovery
+	List          string        `json:"list,omitempty"`         // "" is the default list
+	Priority      Priority      `json:"priority,omitempty"`
+	Due           time.Time     `json:"due,omitzero"` // local midnight when due all day
+	Tags          []string      `json:"tags,omitempty"`
+	Position      int           `json:"position,omitempty"`   // manual sort order within the list; 0 if never placed
+	ParentID      uuid.UUID     `json:"parent_id,omitzero"`   // set on subtasks
+	BlockedBy     []uuid.UUID   `json:"blocked_by,omitempty"` // tasks that must be completed before this one starts
+	Recur         Recurrence    `json:"recur,omitzero"`
+	Notes         string        `json:"notes,omitempty"`
+	CompletedAt   time.Time     `json:"completed_at,omitzero"`
+	Rate          Rate          `json:"rate,omitzero"` // overrides the tag's or list's rate
+}
+
+// start opens a new session on the task.
+func (t *Task) start(now time.Time) {
+	t.Status = InProgress
+	t.LastStartedAt = now
+}
+
+// stop closes the running session, if any, and records it in Sessions.
+// The caller decides which status the task moves to.
+func (t *Task) stop(now time.Time) (Session, bool) {
+	if t.Status != InProgress || t.LastStartedAt.IsZero() {
+		return Session{}, false
+	}
+	session := Session{Start: t.LastStartedAt, End: now}
+	t.Sessions = append(t.Sessions, session)
+	t.LastStartedAt = time.Time{}
+	t.recalcTimeSpent()
+	return session, true
+}
+
+func (t *Task) recalcTimeSpent() {
+	var total time.Duration
+	for _, s := range t.Sessions {
+		total += s.Duration()
+	}
+	t.TimeSpent = total
+}
+
+// cloneTask returns a copy of task that shares no slices with it.
+func cloneTask(task Task) Task {
+	task.Sessions = slices.Clone(task.Sessions)
+	task.Tags = slices.Clone(task.Tags)
+	task.BlockedBy = slices.Clone(task.BlockedBy)
+	return task
+}
+
+func cloneTasks(tasks []Task) []Task {
+	cloned := make([]Task, len(tasks))
+	for i, task := range tasks {
+		cloned[i] = cloneTask(task)
+	}
+	return cloned
+}
+
+// elapsed returns the tracked time including the running session.
+func (t Task) elapsed(now time.Time) time.Duration {
+	d := t.TimeSpent
+	if t.Status == InProgress && !t.LastStartedAt.IsZero() {
+		d += now.Sub(t.LastStartedAt)
+	}
+	return d
 }
 
 type model struct {
@@ -115,6 +423,28 @@ type model struct {
 	showLineNumbers   bool
 	ready             bool
 	useJalaliCalendar bool
+	store             Store
+	lastAutosave      time.Time
+	recovering        []int    // indices of tasks found running at startup
+	lists             []string // list names in tab order; "" is the default list
+	listIndex         int
+	undoStack         [][]Task // snapshots of m.tasks before each change
+	redoStack         [][]Task
+	sortOrder         sortOrder
+	tagFilter         string // only tasks with this tag are shown, if set
+	filterQuery       string // as typed in the filter bar
+	filter            taskFilter
+	collapsed         map[uuid.UUID]bool // tasks whose subtasks are hidden
+	addParent         uuid.UUID          // parent of the subtasks being added, if any
+	blockTarget       uuid.UUID          // task a blocker is being picked for
+	notes             textarea.Model
+	showDetail        bool
+	archive           Store  // where archived tasks are moved
+	archived          []Task // tasks in the archive, most recently archived first
+	archiveCursor     int
+	reportWeek        int    // how many weeks back the report is
+	reportArchived    []Task // archived tasks with time in that week
+	rates             Rates
 }
 
 type appMode int
@@ -122,12 +452,30 @@ type appMode int
 const (
 	modeViewTasks appMode = iota
 	modeAddTask
+	modeRecoverTimers
+	modeAddList
+	modeMoveTask
+	modeEditTask
+	modeFilter
+	modePickBlocker
+	modeEditNotes
+	modeArchive
+	modeReport
+	modeSetRate
 )
 
+// inputCharLimit caps what can be typed into the input box. Editing lifts
+// it, since a task added from the command line can be longer and the edit
+// text adds its tags, due date and repeat rule after the description.
+const inputCharLimit = 156
+
+// autosaveInterval is how often running timers are checkpointed to disk.
+const autosaveInterval = 30 * time.Second
+
 type TickMsg time.Time
 
 type KeyMap struct {
-	Add, Delete, Toggle, Complete, Up, Down, Quit, Enter, Esc, ScrollUp, ScrollDown, ToggleLineNumbers, ToggleCalendar key.Binding
+	Add, Edit, Delete, Toggle, Complete, Up, Down, Quit, Enter, Esc, ScrollUp, ScrollDown, ToggleLineNumbers, ToggleCalendar, RecoverKeep, RecoverEnd, RecoverDiscard, NextList, PrevList, NewList, MoveTask, Undo, Redo, RaisePriority, LowerPriority, Sort, MoveUp, MoveDown, FilterTag, Filter, AddSubtask, Collapse, Expand, PickBlocker, ClearBlockers, ToggleDetail, EditNotes, SaveNotes, Reopen, Archive, ArchiveView, Report, PrevWeek, NextWeek, SetRate key.Binding
 }
 
 var (
@@ -142,10 +490,27 @@ var (
 	timeRenderWidth        int
 	dateRenderWidth        int
 	lineNumberWidth        int
+	priorityRenderWidth    int
+	dueRenderWidth         int
 	statusPendingStyle     lipgloss.Style
 	statusInProgressStyle  lipgloss.Style
 	statusPausedStyle      lipgloss.Style
 	statusCompletedStyle   lipgloss.Style
+	statusBlockedStyle     lipgloss.Style
+	priorityStyle          lipgloss.Style
+	urgentPriorityStyle    lipgloss.Style
+	dueOverdueStyle        lipgloss.Style
+	dueTodayStyle          lipgloss.Style
+	dueLaterStyle          lipgloss.Style
+	tagChipStyle           lipgloss.Style
+	filterBarStyle         lipgloss.Style
+	completionStyle        lipgloss.Style
+	recurStyle             lipgloss.Style
+	detailPaneStyle        lipgloss.Style
+	detailTitleStyle       lipgloss.Style
+	detailLabelStyle       lipgloss.Style
+	reportHeadingStyle     lipgloss.Style
+	detailEmptyStyle       lipgloss.Style
 	descriptionStyle       lipgloss.Style
 	timeTextSyle           lipgloss.Style
 	dateTextSyle           lipgloss.Style
@@ -156,6 +521,9 @@ var (
 	blurredInputStyle      lipgloss.Style
 	helpStyle              lipgloss.Style
 	errorStyle             lipgloss.Style
+	listTabBarStyle        lipgloss.Style
+	listTabStyle           lipgloss.Style
+	activeListTabStyle     lipgloss.Style
 )
 
 const appHorizontalPadding = 2
@@ -177,11 +545,28 @@ func (m *model) initializeStyles() {
 	timeRenderWidth = lipgloss.Width("[00:00:00]") + 1
 	dateRenderWidth = lipgloss.Width("(00/00)") + 1
 	lineNumberWidth = lipgloss.Width("999. ")
+	priorityRenderWidth = lipgloss.Width(priorityUrgentMarker) + 1
+	dueRenderWidth = lipgloss.Width(dueLaterMarker+" 00/00") + 1
 
 	statusPendingStyle = lipgloss.NewStyle()
 	statusInProgressStyle = lipgloss.NewStyle()
 	statusPausedStyle = lipgloss.NewStyle()
 	statusCompletedStyle = lipgloss.NewStyle()
+	statusBlockedStyle = lipgloss.NewStyle().Faint(true)
+	priorityStyle = lipgloss.NewStyle().Bold(true)
+	urgentPriorityStyle = lipgloss.NewStyle().Bold(true).Reverse(true)
+	dueOverdueStyle = lipgloss.NewStyle().Bold(true).Reverse(true)
+	dueTodayStyle = lipgloss.NewStyle().Bold(true)
+	dueLaterStyle = lipgloss.NewStyle()
+	tagChipStyle = lipgloss.NewStyle().Italic(true).Underline(true)
+	filterBarStyle = lipgloss.NewStyle().Padding(0, 1)
+	completionStyle = lipgloss.NewStyle().Faint(true)
+	recurStyle = lipgloss.NewStyle().Faint(true)
+	detailPaneStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	detailTitleStyle = lipgloss.NewStyle().Bold(true)
+	detailLabelStyle = lipgloss.NewStyle().Bold(true)
+	reportHeadingStyle = lipgloss.NewStyle().Bold(true)
+	detailEmptyStyle = lipgloss.NewStyle().Faint(true)
 
 	descriptionStyle = lipgloss.NewStyle().Align(lipgloss.Left)
 	timeTextSyle = lipgloss.NewStyle()
@@ -200,12 +585,17 @@ func (m *model) initializeStyles() {
 
 	helpStyle = lipgloss.NewStyle().Padding(0, 1).Bold(true)
 	errorStyle = lipgloss.NewStyle().Bold(true).Padding(0, 1).MarginBottom(1).Border(lipgloss.RoundedBorder()).Align(lipgloss.Center)
+	listTabBarStyle = lipgloss.NewStyle().Padding(0, 1)
+	listTabStyle = lipgloss.NewStyle().Padding(0, 1)
+	activeListTabStyle = lipgloss.NewStyle().Padding(0, 1).Bold(true).Reverse(true)
 
 	m.keyMap = KeyMap{
 		Add:               key.NewBinding(key.WithKeys("a"), key.WithHelp("a", helpAdd)),
+		Edit:              key.NewBinding(key.WithKeys("e"), key.WithHelp("e", helpEdit)),
 		Delete:            key.NewBinding(key.WithKeys("d"), key.WithHelp("d", helpDelete)),
 		Toggle:            key.NewBinding(key.WithKeys("s"), key.WithHelp("s", helpToggle)),
 		Complete:          key.NewBinding(key.WithKeys("c"), key.WithHelp("c", helpComplete)),
+		Reopen:            key.NewBinding(key.WithKeys("r"), key.WithHelp("r", helpReopen)),
 		Up:                key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", helpNav)),
 		Down:              key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", helpNav)),
 		Quit:              key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", helpQuit)),
@@ -215,21 +605,57 @@ func (m *model) initializeStyles() {
 		ScrollDown:        key.NewBinding(key.WithKeys("pgdown"), key.WithHelp("pgdown", helpScrollDown)),
 		ToggleLineNumbers: key.NewBinding(key.WithKeys("n"), key.WithHelp("n", helpToggleLineNumbers)),
 		ToggleCalendar:    key.NewBinding(key.WithKeys("j"), key.WithHelp("j", helpToggleCalendar)),
+		RecoverKeep:       key.NewBinding(key.WithKeys("k"), key.WithHelp("k", helpRecoverKeep)),
+		RecoverEnd:        key.NewBinding(key.WithKeys("e"), key.WithHelp("e", helpRecoverEnd)),
+		RecoverDiscard:    key.NewBinding(key.WithKeys("x"), key.WithHelp("x", helpRecoverDiscard)),
+		NextList:          key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", helpSwitchList)),
+		PrevList:          key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", helpSwitchList)),
+		NewList:           key.NewBinding(key.WithKeys("L"), key.WithHelp("L", helpNewList)),
+		MoveTask:          key.NewBinding(key.WithKeys("m"), key.WithHelp("m", helpMoveTask)),
+		Undo:              key.NewBinding(key.WithKeys("u"), key.WithHelp("u", helpUndo)),
+		Redo:              key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", helpRedo)),
+		RaisePriority:     key.NewBinding(key.WithKeys("+", "="), key.WithHelp("+", helpPriority)),
+		LowerPriority:     key.NewBinding(key.WithKeys("-"), key.WithHelp("-", helpPriority)),
+		Sort:              key.NewBinding(key.WithKeys("o"), key.WithHelp("o", helpSort)),
+		MoveUp:            key.NewBinding(key.WithKeys("shift+up"), key.WithHelp("shift+↑", helpMoveInOrder)),
+		MoveDown:          key.NewBinding(key.WithKeys("shift+down"), key.WithHelp("shift+↓", helpMoveInOrder)),
+		FilterTag:         key.NewBinding(key.WithKeys("t"), key.WithHelp("t", helpFilterTag)),
+		Filter:            key.NewBinding(key.WithKeys("/"), key.WithHelp("/", helpFilter)),
+		AddSubtask:        key.NewBinding(key.WithKeys("A"), key.WithHelp("A", helpAddSubtask)),
+		Collapse:          key.NewBinding(key.WithKeys("left"), key.WithHelp("←", helpCollapse)),
+		Expand:            key.NewBinding(key.WithKeys("right"), key.WithHelp("→", helpCollapse)),
+		PickBlocker:       key.NewBinding(key.WithKeys("b"), key.WithHelp("b", helpPickBlocker)),
+		ClearBlockers:     key.NewBinding(key.WithKeys("B"), key.WithHelp("B", helpClearBlockers)),
+		ToggleDetail:      key.NewBinding(key.WithKeys("i"), key.WithHelp("i", helpDetail)),
+		EditNotes:         key.NewBinding(key.WithKeys("N"), key.WithHelp("N", helpNotes)),
+		SaveNotes:         key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", helpSave)),
+		Archive:           key.NewBinding(key.WithKeys("x"), key.WithHelp("x", helpArchive)),
+		ArchiveView:       key.NewBinding(key.WithKeys("X"), key.WithHelp("X", helpArchiveView)),
+		Report:            key.NewBinding(key.WithKeys("w"), key.WithHelp("w", helpReport)),
+		PrevWeek:          key.NewBinding(key.WithKeys("left"), key.WithHelp("←", helpWeek)),
+		NextWeek:          key.NewBinding(key.WithKeys("right"), key.WithHelp("→", helpWeek)),
+		SetRate:           key.NewBinding(key.WithKeys("$"), key.WithHelp("$", helpRate)),
 	}
 	m.helpMsg = generateHelp(m.keyMap, m.mode)
 	m.input.Placeholder = inputPlaceholder
 }
 
-func initialModel() model {
+// initialModel loads the tasks and the archive, first archiving tasks
+// completed more than archiveAfter days ago.
+func initialModel(store, archive Store, archiveAfter int) model {
 	m := model{
 		showLineNumbers:   false,
 		useJalaliCalendar: false,
+		store:             store,
+		archive:           archive,
+		collapsed:         map[uuid.UUID]bool{},
 	}
 
 	ti := textinput.New()
-	ti.CharLimit = 156
+	ti.CharLimit = inputCharLimit
 	ti.Width = 50
 	m.input = ti
+	m.notes = newNotesInput()
 
 	m.initializeStyles()
 
@@ -237,14 +663,49 @@ func initialModel() model {
 	m.viewport = vp
 	m.viewport.Style = taskViewportStyle
 
-	loadedTasks, loadErr := loadTasksFromFile(tasksFilename)
+	loadedTasks, loadErr := m.store.Load()
 	if loadErr != nil && !os.IsNotExist(loadErr) {
 		fmt.Fprintf(os.Stderr, errorLoadingTasksLog, loadErr)
 	}
 	m.tasks = loadedTasks
 	m.err = loadErr
+	if err := m.loadLists(); err != nil && m.err == nil {
+		m.err = err
+	}
+	if rates, err := m.store.Rates(); err != nil && m.err == nil {
+		m.err = err
+	} else {
+		m.rates = rates
+	}
+	if archived, err := loadArchive(m.archive); err != nil {
+		m.err = err
+	} else {
+		m.archived = archived
+		m.autoArchive(archiveAfter, time.Now())
+	}
+	// Open in the hand-curated order once there is one.
+	for _, task := range m.tasks {
+		if task.Position != 0 {
+			m.sortOrder = sortManual
+			break
+		}
+	}
+
+	// Running timers in a file locked by another instance belong to that
+	// instance, not to a crashed session, and timers never autosaved were
+	// started from the command line.
+	if !m.store.ReadOnly() {
+		for i, task := range m.tasks {
+			if task.Status == InProgress && !task.LastStartedAt.IsZero() && !task.LastSavedAt.IsZero() {
+				m.recovering = append(m.recovering, i)
+			}
+		}
+	}
 
-	if len(m.tasks) == 0 && loadErr == nil {
+	if len(m.recovering) > 0 {
+		m.mode = modeRecoverTimers
+		m.input.Blur()
+	} else if len(m.tasks) == 0 && loadErr == nil {
 		m.mode = modeAddTask
 		m.input.Focus()
 	} else {
@@ -255,6 +716,112 @@ func initialModel() model {
 	return m
 }
 
+func (m *model) persist(err error) {
+	if err != nil {
+		m.err = fmt.Errorf(errorSave, err)
+	}
+}
+
+// saveTask persists the task at index i, stamping it if running so its timer
+// can be recovered if the process dies before the next save.
+func (m *model) saveTask(i int) {
+	if m.tasks[i].Status == InProgress {
+		m.tasks[i].LastSavedAt = time.Now()
+	}
+	m.persist(m.store.UpsertTask(m.tasks[i]))
+}
+
+// stopTask closes the running session of the task at index i, if any, and
+// moves the task to status.
+func (m *model) stopTask(i int, now time.Time, status TaskStatus) {
+	if session, ok := m.tasks[i].stop(now); ok {
+		m.persist(m.store.AppendSession(m.tasks[i].ID, session))
+	}
+	m.tasks[i].Status = status
+	m.saveTask(i)
+}
+
+// completeTask stops and completes the task at i and, if it repeats, adds
+// its next instance.
+func (m *model) completeTask(i int, now time.Time) {
+	next, repeats := m.tasks[i].nextRecurrence(now)
+	id := m.tasks[i].ID
+	m.tasks[i].CompletedAt = now
+	m.stopTask(i, now, Completed)
+	if repeats {
+		m.tasks = append([]Task{next}, m.tasks...)
+		m.persist(m.store.UpsertTask(next))
+	}
+	m.selectTask(id)
+}
+
+// reopenTask returns the completed task at i to Paused, keeping its time.
+func (m *model) reopenTask(i int) {
+	m.tasks[i].Status = Paused
+	m.tasks[i].CompletedAt = time.Time{}
+	m.saveTask(i)
+}
+
+// autosave checkpoints every running timer.
+func (m *model) autosave() {
+	for i := range m.tasks {
+		if m.tasks[i].Status == InProgress {
+			m.saveTask(i)
+		}
+	}
+	m.lastAutosave = time.Now()
+}
+
+// resolveRecovery settles the first timer left running by a previous session
+// and leaves recovery mode once none remain.
+func (m *model) resolveRecovery(msg tea.KeyMsg) {
+	if len(m.recovering) == 0 {
+		return
+	}
+	i := m.recovering[0]
+	task := &m.tasks[i]
+	end := time.Now()
+	switch {
+	case key.Matches(msg, m.keyMap.RecoverKeep):
+	case key.Matches(msg, m.keyMap.RecoverEnd):
+		if task.LastSavedAt.After(task.LastStartedAt) {
+			end = task.LastSavedAt
+		} else {
+			task.LastStartedAt = time.Time{}
+		}
+	case key.Matches(msg, m.keyMap.RecoverDiscard):
+		task.LastStartedAt = time.Time{}
+	default:
+		return
+	}
+	m.stopTask(i, end, Paused)
+	m.recovering = m.recovering[1:]
+
+	if len(m.recovering) == 0 {
+		m.mode = modeViewTasks
+		m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+	}
+}
+
+func (m model) renderRecoveryPrompt() string {
+	if len(m.recovering) == 0 {
+		return ""
+	}
+	task := m.tasks[m.recovering[0]]
+	lastSaved := recoverNeverSaved
+	tracked := time.Duration(0)
+	if task.LastSavedAt.After(task.LastStartedAt) {
+		lastSaved = task.LastSavedAt.Format("2006-01-02 15:04:05")
+		tracked = task.LastSavedAt.Sub(task.LastStartedAt)
+	}
+	return fmt.Sprintf(recoverPrompt,
+		task.Description,
+		task.LastStartedAt.Format("2006-01-02 15:04:05"),
+		lastSaved,
+		formatDuration(tracked),
+	)
+}
+
 func (m model) Init() tea.Cmd {
 	return tea.Batch(textinput.Blink, doTick())
 }
@@ -264,10 +831,13 @@ func doTick() tea.Cmd {
 }
 
 func (m *model) ensureCursorVisible() {
-	if len(m.tasks) == 0 {
+	cursorLine, count := m.cursor, len(m.visibleTasks())
+	if m.mode == modeArchive {
+		cursorLine, count = m.archiveCursor, len(m.archived)
+	}
+	if count == 0 {
 		return
 	}
-	cursorLine := m.cursor
 	if cursorLine < m.viewport.YOffset {
 		m.viewport.SetYOffset(cursorLine)
 	} else if cursorLine >= m.viewport.YOffset+m.viewport.Height {
@@ -290,53 +860,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.height = msg.Height
 		}
 
-		availableWidth := m.width - appHorizontalPadding
-		currentAvailableHeight := m.height - appVerticalPadding
-
-		titleViewHeight := lipgloss.Height(titleStyle.Render(title))
-		currentAvailableHeight -= titleViewHeight
-
-		statsBarContent := m.renderStatsBar()
-		statsBarHeight := lipgloss.Height(statsStyle.Render(statsBarContent))
-		currentAvailableHeight -= statsBarHeight
-
-		calendarIndicatorText := calendarGregorian
-		if m.useJalaliCalendar {
-			calendarIndicatorText = calendarJalali
-		}
-		calendarIndicatorHeight := lipgloss.Height(calendarIndicatorStyle.Render(calendarIndicatorText))
-		currentAvailableHeight -= calendarIndicatorHeight
-
-		helpViewHeight := lipgloss.Height(helpStyle.Render(m.helpMsg))
-		currentAvailableHeight -= helpViewHeight
-
-		if m.err != nil {
-			errorViewHeight := lipgloss.Height(errorStyle.Render(fmt.Sprintf(errorPrefix, m.err)))
-			currentAvailableHeight -= errorViewHeight
-		}
-
-		m.viewport.Width = max(1, availableWidth-taskViewportStyle.GetHorizontalFrameSize())
-
-		if m.mode == modeAddTask {
-			inputContentForHeight := lipgloss.JoinVertical(lipgloss.Left,
-				lipgloss.NewStyle().Bold(true).Align(lipgloss.Center).Render(inputAreaTitle),
-				lipgloss.JoinHorizontal(lipgloss.Bottom,
-					inputPromptStyle.Render(newTaskPrompt),
-					focusedInputStyle.Width(m.input.Width).Render(" "),
-				),
-			)
-			inputAreaRenderedHeight := lipgloss.Height(inputAreaStyle.Render(inputContentForHeight))
-			currentAvailableHeight -= inputAreaRenderedHeight
-
-			inputPromptRenderedWidth := lipgloss.Width(inputPromptStyle.Render(newTaskPrompt))
-			m.input.Width = max(10, availableWidth-inputAreaStyle.GetHorizontalFrameSize()-inputPromptRenderedWidth-2)
-		} else {
-			m.viewport.Height = max(1, currentAvailableHeight-taskViewportStyle.GetVerticalFrameSize())
-		}
-		m.viewport.SetContent(m.renderTasksView())
+		m.updateLayout()
 
 	case TickMsg:
 		// This message will cause Bubble Tea to call View(), which handles the re-render.
+		if m.mode != modeRecoverTimers && time.Since(m.lastAutosave) >= autosaveInterval {
+			m.autosave()
+		}
 		return m, doTick()
 
 	case tea.KeyMsg:
@@ -347,6 +877,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		switch m.mode {
+		case modeRecoverTimers:
+			if key.Matches(msg, m.keyMap.Quit) {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			m.resolveRecovery(msg)
 		case modeViewTasks:
 			switch {
 			case key.Matches(msg, m.keyMap.ToggleLineNumbers):
@@ -357,31 +893,50 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.viewport.SetContent(m.renderTasksView()) // Explicitly re-render
 			case key.Matches(msg, m.keyMap.Quit):
 				m.quitting = true
+				now := time.Now()
 				for i := range m.tasks {
 					if m.tasks[i].Status == InProgress {
-						if !m.tasks[i].LastStartedAt.IsZero() {
-							m.tasks[i].TimeSpent += time.Since(m.tasks[i].LastStartedAt)
-						}
-						m.tasks[i].Status = Paused
+						m.stopTask(i, now, Paused)
 					}
 				}
-				if err := saveTasksToFile(tasksFilename, m.tasks); err != nil {
-					m.err = fmt.Errorf(errorSave, err)
-				}
 				return m, tea.Quit
 			case key.Matches(msg, m.keyMap.Add):
+				m.addParent = uuid.Nil
 				m.mode = modeAddTask
 				m.input.SetValue("")
 				m.input.Focus()
 				m.helpMsg = generateHelp(m.keyMap, modeAddTask)
 				return m, textinput.Blink
+			case key.Matches(msg, m.keyMap.Edit):
+				if selected, ok := m.selectedTask(); ok {
+					m.mode = modeEditTask
+					value := m.tasks[selected].Description
+					if tags := m.tasks[selected].Tags; len(tags) > 0 {
+						value += " " + formatTagsInput(tags)
+					}
+					if due := m.tasks[selected].Due; !due.IsZero() {
+						value += " " + formatDueInput(due, m.useJalaliCalendar)
+					}
+					if recur := m.tasks[selected].Recur; !recur.IsZero() {
+						value += " " + recurKeyword + recur.String()
+					}
+					m.input.CharLimit = 0
+					m.input.SetValue(value)
+					m.input.CursorEnd()
+					m.input.Focus()
+					m.helpMsg = generateHelp(m.keyMap, modeEditTask)
+					return m, textinput.Blink
+				}
 			case key.Matches(msg, m.keyMap.Delete):
-				if len(m.tasks) > 0 && m.cursor < len(m.tasks) {
-					m.tasks = append(m.tasks[:m.cursor], m.tasks[m.cursor+1:]...)
-					if m.cursor >= len(m.tasks) && len(m.tasks) > 0 {
-						m.cursor = len(m.tasks) - 1
-					} else if len(m.tasks) == 0 {
+				if selected, ok := m.selectedTask(); ok {
+					m.checkpoint()
+					m.deleteTask(selected)
+					visibleCount := len(m.visibleTasks())
+					if m.cursor >= visibleCount && visibleCount > 0 {
+						m.cursor = visibleCount - 1
+					} else if visibleCount == 0 {
 						m.cursor = 0
+						m.addParent = uuid.Nil
 						m.mode = modeAddTask
 						m.input.Focus()
 						m.helpMsg = generateHelp(m.keyMap, modeAddTask)
@@ -389,62 +944,124 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 			case key.Matches(msg, m.keyMap.Up):
-				if len(m.tasks) > 0 {
+				if len(m.visibleTasks()) > 0 {
 					if m.cursor > 0 {
 						m.cursor--
 						m.ensureCursorVisible()
 					}
 				}
 			case key.Matches(msg, m.keyMap.Down):
-				if len(m.tasks) > 0 {
-					if m.cursor < len(m.tasks)-1 {
+				if visibleCount := len(m.visibleTasks()); visibleCount > 0 {
+					if m.cursor < visibleCount-1 {
 						m.cursor++
 						m.ensureCursorVisible()
 					}
 				}
 			case key.Matches(msg, m.keyMap.Toggle):
-				if len(m.tasks) > 0 && m.cursor < len(m.tasks) {
-					task := &m.tasks[m.cursor]
-					switch task.Status {
+				if selected, ok := m.selectedTask(); ok && m.tasks[selected].Status != Completed {
+					if m.tasks[selected].Status != InProgress && isBlocked(m.tasks, selected) {
+						m.err = blockedError(m.tasks, selected)
+						break
+					}
+					m.checkpoint()
+					now := time.Now()
+					switch m.tasks[selected].Status {
 					case Pending, Paused:
 						for i := range m.tasks {
-							if m.tasks[i].Status == InProgress && i != m.cursor {
-								if !m.tasks[i].LastStartedAt.IsZero() {
-									m.tasks[i].TimeSpent += time.Since(m.tasks[i].LastStartedAt)
-								}
-								m.tasks[i].Status = Paused
+							if m.tasks[i].Status == InProgress && i != selected {
+								m.stopTask(i, now, Paused)
 							}
 						}
-						task.Status = InProgress
-						task.LastStartedAt = time.Now()
+						m.tasks[selected].start(now)
+						m.saveTask(selected)
 					case InProgress:
-						task.Status = Paused
-						if !task.LastStartedAt.IsZero() {
-							task.TimeSpent += time.Since(task.LastStartedAt)
-						}
+						m.stopTask(selected, now, Paused)
 					}
 				}
 			case key.Matches(msg, m.keyMap.Complete):
-				if len(m.tasks) > 0 && m.cursor < len(m.tasks) {
-					if m.tasks[m.cursor].Status == InProgress {
-						if !m.tasks[m.cursor].LastStartedAt.IsZero() {
-							m.tasks[m.cursor].TimeSpent += time.Since(m.tasks[m.cursor].LastStartedAt)
-						}
-					}
-					m.tasks[m.cursor].Status = Completed
+				if selected, ok := m.selectedTask(); ok && m.tasks[selected].Status != Completed {
+					m.checkpoint()
+					m.completeTask(selected, time.Now())
+				}
+			case key.Matches(msg, m.keyMap.Reopen):
+				if selected, ok := m.selectedTask(); ok && m.tasks[selected].Status == Completed {
+					m.checkpoint()
+					m.reopenTask(selected)
 				}
+			case key.Matches(msg, m.keyMap.Undo):
+				m.undo()
+			case key.Matches(msg, m.keyMap.Redo):
+				m.redo()
+			case key.Matches(msg, m.keyMap.NextList):
+				m.switchList(1)
+			case key.Matches(msg, m.keyMap.PrevList):
+				m.switchList(-1)
+			case key.Matches(msg, m.keyMap.NewList):
+				return m, m.startListInput(modeAddList)
+			case key.Matches(msg, m.keyMap.MoveTask):
+				if _, ok := m.selectedTask(); ok {
+					return m, m.startListInput(modeMoveTask)
+				}
+			case key.Matches(msg, m.keyMap.RaisePriority):
+				m.changePriority(1)
+			case key.Matches(msg, m.keyMap.LowerPriority):
+				m.changePriority(-1)
+			case key.Matches(msg, m.keyMap.FilterTag):
+				m.cycleTagFilter()
+			case key.Matches(msg, m.keyMap.Filter):
+				return m, m.startFilter()
+			case key.Matches(msg, m.keyMap.AddSubtask):
+				return m, m.startSubtaskInput()
+			case key.Matches(msg, m.keyMap.Collapse):
+				m.setCollapsed(true)
+			case key.Matches(msg, m.keyMap.Expand):
+				m.setCollapsed(false)
+			case key.Matches(msg, m.keyMap.PickBlocker):
+				m.startBlockerPick()
+			case key.Matches(msg, m.keyMap.ClearBlockers):
+				m.clearBlockers()
+			case key.Matches(msg, m.keyMap.ToggleDetail):
+				m.showDetail = !m.showDetail
+				m.updateLayout()
+			case key.Matches(msg, m.keyMap.EditNotes):
+				return m, m.startNotesInput()
+			case key.Matches(msg, m.keyMap.Archive):
+				m.archiveSelected()
+			case key.Matches(msg, m.keyMap.ArchiveView):
+				m.startArchiveView()
+			case key.Matches(msg, m.keyMap.Report):
+				m.startReportView()
+			case key.Matches(msg, m.keyMap.SetRate):
+				return m, m.startRateInput()
+			case key.Matches(msg, m.keyMap.Esc):
+				if m.filterQuery != "" {
+					m.applyFilter("")
+					m.updateLayout()
+				}
+			case key.Matches(msg, m.keyMap.Sort):
+				m.cycleSortOrder()
+			case key.Matches(msg, m.keyMap.MoveUp):
+				m.moveSelectedInOrder(-1)
+			case key.Matches(msg, m.keyMap.MoveDown):
+				m.moveSelectedInOrder(1)
 			}
 		case modeAddTask:
 			switch {
 			case key.Matches(msg, m.keyMap.Enter):
-				if strings.TrimSpace(m.input.Value()) != "" {
-					newTask := Task{ID: uuid.New(), Description: m.input.Value(), Status: Pending, CreatedAt: time.Now()}
+				input, err := parseTaskInput(m.input.Value(), time.Now(), m.useJalaliCalendar)
+				if err != nil {
+					m.err = err
+				} else if strings.TrimSpace(input.Description) != "" {
+					newTask := Task{ID: uuid.New(), Description: input.Description, Status: Pending, CreatedAt: time.Now(), List: m.currentList(), Due: input.Due, Tags: input.Tags, ParentID: m.addParent, Recur: input.Recur}
+					m.checkpoint()
 					m.tasks = append([]Task{newTask}, m.tasks...) // Prepend to add to top
+					m.persist(m.store.UpsertTask(newTask))
 					m.input.SetValue("")
-					m.cursor = 0 // Set cursor to the new task
+					m.selectTask(newTask.ID)
 				}
 			case key.Matches(msg, m.keyMap.Esc):
 				m.mode = modeViewTasks
+				m.addParent = uuid.Nil
 				m.input.Blur()
 				m.input.SetValue("")
 				m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
@@ -452,12 +1069,142 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.input, cmd = m.input.Update(msg)
 				cmds = append(cmds, cmd)
 			}
+		case modeEditTask:
+			switch {
+			case key.Matches(msg, m.keyMap.Enter):
+				input, err := parseTaskInput(m.input.Value(), time.Now(), m.useJalaliCalendar)
+				if err != nil {
+					m.err = err
+					break
+				}
+				selected, ok := m.selectedTask()
+				if ok && strings.TrimSpace(input.Description) != "" && (input.Description != m.tasks[selected].Description || !input.Due.Equal(m.tasks[selected].Due) || !slices.Equal(input.Tags, m.tasks[selected].Tags) || input.Recur != m.tasks[selected].Recur) {
+					m.checkpoint()
+					m.tasks[selected].Description = input.Description
+					m.tasks[selected].Due = input.Due
+					m.tasks[selected].Tags = input.Tags
+					m.tasks[selected].Recur = input.Recur
+					m.saveTask(selected)
+				}
+				fallthrough
+			case key.Matches(msg, m.keyMap.Esc):
+				m.mode = modeViewTasks
+				m.input.Blur()
+				m.input.SetValue("")
+				m.input.CharLimit = inputCharLimit
+				m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+			default:
+				m.input, cmd = m.input.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		case modeFilter:
+			switch {
+			case key.Matches(msg, m.keyMap.Enter):
+				m.stopFilter(false)
+			case key.Matches(msg, m.keyMap.Esc):
+				m.stopFilter(true)
+			default:
+				m.input, cmd = m.input.Update(msg)
+				cmds = append(cmds, cmd)
+				m.applyFilter(m.input.Value())
+			}
+		case modeArchive:
+			switch {
+			case key.Matches(msg, m.keyMap.Up):
+				if m.archiveCursor > 0 {
+					m.archiveCursor--
+					m.ensureCursorVisible()
+				}
+			case key.Matches(msg, m.keyMap.Down):
+				if m.archiveCursor < len(m.archived)-1 {
+					m.archiveCursor++
+					m.ensureCursorVisible()
+				}
+			case key.Matches(msg, m.keyMap.Enter):
+				m.restoreSelectedArchived()
+			case key.Matches(msg, m.keyMap.Esc), key.Matches(msg, m.keyMap.ArchiveView):
+				m.stopArchiveView()
+			}
+		case modeReport:
+			switch {
+			case key.Matches(msg, m.keyMap.PrevWeek):
+				m.reportWeek++
+				m.loadReportWeek()
+			case key.Matches(msg, m.keyMap.NextWeek):
+				if m.reportWeek > 0 {
+					m.reportWeek--
+					m.loadReportWeek()
+				}
+			case key.Matches(msg, m.keyMap.ToggleCalendar):
+				// The week starts on a different day.
+				m.useJalaliCalendar = !m.useJalaliCalendar
+				m.loadReportWeek()
+			case key.Matches(msg, m.keyMap.Esc), key.Matches(msg, m.keyMap.Report):
+				m.stopReportView()
+			}
+		case modeEditNotes:
+			switch {
+			case key.Matches(msg, m.keyMap.SaveNotes):
+				m.stopNotesInput(true)
+			case key.Matches(msg, m.keyMap.Esc):
+				m.stopNotesInput(false)
+			default:
+				m.notes, cmd = m.notes.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		case modePickBlocker:
+			switch {
+			case key.Matches(msg, m.keyMap.Up):
+				if m.cursor > 0 {
+					m.cursor--
+					m.ensureCursorVisible()
+				}
+			case key.Matches(msg, m.keyMap.Down):
+				if m.cursor < len(m.visibleTasks())-1 {
+					m.cursor++
+					m.ensureCursorVisible()
+				}
+			case key.Matches(msg, m.keyMap.Enter):
+				m.finishBlockerPick(true)
+			case key.Matches(msg, m.keyMap.Esc):
+				m.finishBlockerPick(false)
+			}
+		case modeSetRate:
+			switch {
+			case key.Matches(msg, m.keyMap.Enter):
+				m.stopRateInput(true)
+			case key.Matches(msg, m.keyMap.Esc):
+				m.stopRateInput(false)
+			default:
+				m.input, cmd = m.input.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+		case modeAddList, modeMoveTask:
+			switch {
+			case key.Matches(msg, m.keyMap.Enter):
+				name := listNameFromInput(m.input.Value())
+				if m.mode == modeAddList {
+					if name != "" {
+						m.listIndex = m.ensureList(name)
+						m.tagFilter = ""
+						m.cursor = 0
+					}
+				} else {
+					m.moveSelectedTask(name)
+				}
+				m.stopListInput()
+			case key.Matches(msg, m.keyMap.Esc):
+				m.stopListInput()
+			default:
+				m.input, cmd = m.input.Update(msg)
+				cmds = append(cmds, cmd)
+			}
 		}
 	}
 
-	if len(m.tasks) > 0 {
-		if m.cursor >= len(m.tasks) {
-			m.cursor = len(m.tasks) - 1
+	if visibleCount := len(m.visibleTasks()); visibleCount > 0 {
+		if m.cursor >= visibleCount {
+			m.cursor = visibleCount - 1
 		}
 		if m.cursor < 0 {
 			m.cursor = 0
@@ -467,16 +1214,158 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	// Always update viewport content after a state change that affects it.
-	m.viewport.SetContent(m.renderTasksView())
+	m.viewport.SetContent(m.renderViewportContent())
 	m.viewport, cmd = m.viewport.Update(msg)
 	cmds = append(cmds, cmd)
 
 	return m, tea.Batch(cmds...)
 }
 
+// updateLayout sizes the viewport and input to the window and to whatever
+// is shown around them.
+func (m *model) updateLayout() {
+	availableWidth := m.width - appHorizontalPadding
+	currentAvailableHeight := m.height - appVerticalPadding
+
+	titleViewHeight := lipgloss.Height(titleStyle.Render(title))
+	currentAvailableHeight -= titleViewHeight
+
+	statsBarContent := m.renderStatsBar()
+	statsBarHeight := lipgloss.Height(statsStyle.Width(availableWidth).Render(statsBarContent))
+	currentAvailableHeight -= statsBarHeight
+
+	calendarIndicatorText := calendarGregorian
+	if m.useJalaliCalendar {
+		calendarIndicatorText = calendarJalali
+	}
+	calendarIndicatorHeight := lipgloss.Height(calendarIndicatorStyle.Render(calendarIndicatorText))
+	currentAvailableHeight -= calendarIndicatorHeight
+
+	currentAvailableHeight -= lipgloss.Height(m.renderListTabs())
+	if m.showFilterBar() {
+		currentAvailableHeight -= lipgloss.Height(m.renderFilterBar())
+	}
+
+	helpViewHeight := lipgloss.Height(helpStyle.Width(availableWidth).Render(m.helpMsg))
+	currentAvailableHeight -= helpViewHeight
+
+	if m.err != nil {
+		errorViewHeight := lipgloss.Height(errorStyle.Render(fmt.Sprintf(errorPrefix, m.err)))
+		currentAvailableHeight -= errorViewHeight
+	}
+
+	m.viewport.Width = max(1, availableWidth-taskViewportStyle.GetHorizontalFrameSize())
+
+	if m.isInputMode() {
+		inputTitle, inputPrompt := m.inputLabels()
+		inputContentForHeight := lipgloss.JoinVertical(lipgloss.Left,
+			lipgloss.NewStyle().Bold(true).Align(lipgloss.Center).Render(inputTitle),
+			lipgloss.JoinHorizontal(lipgloss.Bottom,
+				inputPromptStyle.Render(inputPrompt),
+				focusedInputStyle.Width(m.input.Width).Render(" "),
+			),
+		)
+		inputAreaRenderedHeight := lipgloss.Height(inputAreaStyle.Render(inputContentForHeight))
+		currentAvailableHeight -= inputAreaRenderedHeight
+
+		inputPromptRenderedWidth := lipgloss.Width(inputPromptStyle.Render(inputPrompt))
+		m.input.Width = max(10, availableWidth-inputAreaStyle.GetHorizontalFrameSize()-inputPromptRenderedWidth-2)
+	} else if m.mode == modeEditNotes {
+		notesTitleHeight := lipgloss.Height(lipgloss.NewStyle().Bold(true).Render(notesAreaTitle))
+		m.notes.SetWidth(max(10, availableWidth-inputAreaStyle.GetHorizontalFrameSize()))
+		m.notes.SetHeight(max(3, currentAvailableHeight-inputAreaStyle.GetVerticalFrameSize()-notesTitleHeight))
+	} else {
+		m.viewport.Height = max(1, currentAvailableHeight-taskViewportStyle.GetVerticalFrameSize())
+		if m.detailShown() {
+			if m.detailOnSide() {
+				m.viewport.Width = max(1, m.viewport.Width-m.detailWidth())
+			} else {
+				m.viewport.Height = max(1, m.viewport.Height-detailBottomHeight)
+			}
+		}
+	}
+	if m.mode == modeFilter {
+		m.input.Width = max(10, availableWidth-filterBarStyle.GetHorizontalFrameSize()-lipgloss.Width(filterPrompt+" ")-2)
+	}
+	m.viewport.SetContent(m.renderViewportContent())
+}
+
+// renderViewportContent renders what the viewport shows in the current
+// mode: the archive, the report or the task list.
+func (m *model) renderViewportContent() string {
+	switch m.mode {
+	case modeArchive:
+		return m.renderArchiveView()
+	case modeReport:
+		return m.renderReportView()
+	}
+	return m.renderTasksView()
+}
+
+// isInputMode reports whether the current mode shows the text input box.
+func (m model) isInputMode() bool {
+	return m.mode == modeAddTask || m.mode == modeEditTask || m.mode == modeAddList || m.mode == modeMoveTask || m.mode == modeSetRate
+}
+
+// inputLabels returns the title and prompt of the input box for the mode.
+func (m model) inputLabels() (string, string) {
+	switch m.mode {
+	case modeEditTask:
+		return editAreaTitle, editTaskPrompt
+	case modeAddList:
+		return newListAreaTitle, newListPrompt
+	case modeMoveTask:
+		return moveTaskAreaTitle, moveTaskPrompt
+	case modeSetRate:
+		if i, ok := m.selectedTask(); ok {
+			return fmt.Sprintf(rateAreaTitle, m.tasks[i].Description), ratePrompt
+		}
+		return fmt.Sprintf(rateAreaTitle, ""), ratePrompt
+	default:
+		if i := taskIndex(m.tasks, m.addParent); m.addParent != uuid.Nil && i >= 0 {
+			return fmt.Sprintf(subtaskAreaTitle, m.tasks[i].Description), newTaskPrompt
+		}
+		return inputAreaTitle, newTaskPrompt
+	}
+}
+
+// startListInput opens the input box to name a list, completing from the
+// existing ones.
+func (m *model) startListInput(mode appMode) tea.Cmd {
+	m.mode = mode
+	suggestions := make([]string, len(m.lists))
+	for i, name := range m.lists {
+		suggestions[i] = listDisplayName(name)
+	}
+	m.input.SetValue("")
+	m.input.Placeholder = listPlaceholder
+	m.input.ShowSuggestions = true
+	m.input.SetSuggestions(suggestions)
+	m.input.Focus()
+	m.helpMsg = generateHelp(m.keyMap, mode)
+	return textinput.Blink
+}
+
+func (m *model) stopListInput() {
+	m.mode = modeViewTasks
+	m.input.Blur()
+	m.input.SetValue("")
+	m.input.Placeholder = inputPlaceholder
+	m.input.ShowSuggestions = false
+	m.input.SetSuggestions(nil)
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+}
+
 func (m model) renderStatsBar() string {
-	pendingCount, inProgressCount, completedCount := 0, 0, 0
-	for _, task := range m.tasks {
+	pendingCount, inProgressCount, completedCount, dueSoonCount := 0, 0, 0, 0
+	completedToday, completedThisWeek := 0, 0
+	now := time.Now()
+	today, week := startOfDay(now), startOfWeek(now, m.useJalaliCalendar)
+	for _, i := range m.visibleTasks() {
+		task := m.tasks[i]
+		if task.dueSoon(now) {
+			dueSoonCount++
+		}
 		switch task.Status {
 		case Pending:
 			pendingCount++
@@ -484,12 +1373,21 @@ func (m model) renderStatsBar() string {
 			inProgressCount++
 		case Completed:
 			completedCount++
+			if completedAt, ok := task.completedAt(); ok && !completedAt.Before(week) {
+				completedThisWeek++
+				if !completedAt.Before(today) {
+					completedToday++
+				}
+			}
 		}
 	}
-	return fmt.Sprintf("%s: %d | %s: %d | %s: %d",
+	return fmt.Sprintf("%s — %s: %d | %s: %d | %s: %d (%s) | %s: %d | %s: %s",
+		listDisplayName(m.currentList()),
 		statsPending, pendingCount,
 		statsInProgress, inProgressCount,
-		statsCompleted, completedCount,
+		statsCompleted, completedCount, fmt.Sprintf(statsCompletedRecent, completedToday, completedThisWeek),
+		statsDueSoon, dueSoonCount,
+		statsTracked, formatDuration(m.listTimeSpent(now)),
 	)
 }
 
@@ -519,9 +1417,30 @@ func (m model) View() string {
 	if m.useJalaliCalendar {
 		calendarIndicatorText = "Calendar: " + calendarJalali
 	}
+	if m.sortOrder != sortCreated {
+		calendarIndicatorText += " | " + sortIndicator + m.sortOrder.String()
+	}
+	if m.tagFilter != "" {
+		calendarIndicatorText += " | " + tagFilterIndicator + m.tagFilter
+	}
+	switch m.mode {
+	case modeArchive:
+		calendarIndicatorText += " | " + fmt.Sprintf(archiveIndicator, len(m.archived))
+	case modeReport:
+		calendarIndicatorText += " | " + m.reportIndicatorText()
+	}
+	if m.store.ReadOnly() {
+		calendarIndicatorText += " | " + readOnlyIndicator
+	}
 	viewParts = append(viewParts, calendarIndicatorStyle.Width(m.width-appHorizontalPadding).Render(calendarIndicatorText))
 
-	if m.mode == modeAddTask {
+	viewParts = append(viewParts, m.renderListTabs())
+	if m.showFilterBar() {
+		viewParts = append(viewParts, m.renderFilterBar())
+	}
+
+	if m.isInputMode() {
+		inputTitle, inputPrompt := m.inputLabels()
 		inputCurrentStyle := blurredInputStyle
 		if m.input.Focused() {
 			inputCurrentStyle = focusedInputStyle
@@ -530,26 +1449,51 @@ func (m model) View() string {
 		inputFieldRender := inputCurrentStyle.Width(m.input.Width).Render(m.input.View())
 
 		inputFieldContent := lipgloss.JoinHorizontal(lipgloss.Bottom,
-			inputPromptStyle.Render(newTaskPrompt),
+			inputPromptStyle.Render(inputPrompt),
 			inputFieldRender,
 		)
-		inputBoxTitle := lipgloss.NewStyle().Bold(true).Align(lipgloss.Center).Render(inputAreaTitle)
+		inputBoxTitle := lipgloss.NewStyle().Bold(true).Align(lipgloss.Center).Render(inputTitle)
 		inputBoxContent := lipgloss.JoinVertical(lipgloss.Top, inputBoxTitle, inputFieldContent)
 
 		viewParts = append(viewParts, inputAreaStyle.Width(m.width-appHorizontalPadding).Render(inputBoxContent))
 
+	} else if m.mode == modeEditNotes {
+		viewParts = append(viewParts, m.renderNotesInput())
+	} else if m.mode == modeRecoverTimers {
+		recoverBoxTitle := lipgloss.NewStyle().Bold(true).Align(lipgloss.Center).Render(recoverAreaTitle)
+		recoverBoxContent := lipgloss.JoinVertical(lipgloss.Top, recoverBoxTitle, m.renderRecoveryPrompt())
+		viewParts = append(viewParts, inputAreaStyle.Width(m.width-appHorizontalPadding).Render(recoverBoxContent))
 	} else {
-		if len(m.tasks) == 0 {
+		var taskList string
+		showDetail := m.detailShown()
+		listWidth := m.width - appHorizontalPadding
+		if showDetail && m.detailOnSide() {
+			listWidth = m.viewport.Width - taskViewportStyle.GetHorizontalFrameSize()
+		}
+		empty, emptyMessage := len(m.visibleTasks()) == 0, noTasks
+		switch m.mode {
+		case modeArchive:
+			empty, emptyMessage = len(m.archived) == 0, archiveEmpty
+		case modeReport:
+			empty = false
+		}
+		if empty {
 			noTasksRendered := lipgloss.Place(
 				m.viewport.Width, m.viewport.Height,
 				lipgloss.Center, lipgloss.Center,
-				noTasks,
+				emptyMessage,
 				lipgloss.WithWhitespaceChars(" "),
 			)
-			viewParts = append(viewParts, taskViewportStyle.Width(m.width-appHorizontalPadding).Height(m.viewport.Height+taskViewportStyle.GetVerticalFrameSize()).Render(noTasksRendered))
+			taskList = taskViewportStyle.Width(listWidth).Height(m.viewport.Height + taskViewportStyle.GetVerticalFrameSize()).Render(noTasksRendered)
 		} else {
-			viewParts = append(viewParts, m.viewport.View())
+			taskList = m.viewport.View()
 		}
+		if showDetail && m.detailOnSide() {
+			taskList = lipgloss.JoinHorizontal(lipgloss.Top, taskList, m.renderDetailPane())
+		} else if showDetail {
+			taskList = lipgloss.JoinVertical(lipgloss.Left, taskList, m.renderDetailPane())
+		}
+		viewParts = append(viewParts, taskList)
 	}
 
 	allContentAboveHelp := lipgloss.JoinVertical(lipgloss.Left, viewParts...)
@@ -575,9 +1519,10 @@ func (m model) View() string {
 
 func (m *model) renderTasksView() string {
 	var taskLines []string
-	contentWidth := m.viewport.Width
+	contentWidth := m.viewport.Width - taskViewportStyle.GetHorizontalFrameSize()
 
-	for i, task := range m.tasks {
+	for i, row := range m.visibleRows() {
+		task := m.tasks[row.index]
 		var currentStatusStyle lipgloss.Style
 		switch task.Status {
 		case Pending:
@@ -590,13 +1535,22 @@ func (m *model) renderTasksView() string {
 			currentStatusStyle = statusCompletedStyle
 		}
 		statusText := currentStatusStyle.Render(task.Status.String())
+		if task.Status != InProgress && isBlocked(m.tasks, row.index) {
+			statusText = statusBlockedStyle.Render(statusBlocked)
+		}
 		statusPart := statusText
 
-		timeDisplay := task.TimeSpent
-		if task.Status == InProgress {
-			if !task.LastStartedAt.IsZero() {
-				timeDisplay += time.Since(task.LastStartedAt)
-			}
+		currentPriorityStyle := priorityStyle
+		if task.Priority == PriorityUrgent {
+			currentPriorityStyle = urgentPriorityStyle
+		}
+		priorityPart := lipgloss.NewStyle().Width(priorityRenderWidth).Render(currentPriorityStyle.Render(task.Priority.marker()))
+
+		now := time.Now()
+		timeDisplay := task.elapsed(now)
+		hasSubtasks := len(m.subtasks(row.index)) > 0
+		if hasSubtasks {
+			timeDisplay = m.rolledUpTime(row.index, now)
 		}
 		formattedTime := timeTextSyle.Render("[" + formatDuration(timeDisplay) + "]")
 		timePart := lipgloss.NewStyle().Align(lipgloss.Right).Width(timeRenderWidth).Render(formattedTime)
@@ -627,32 +1581,50 @@ func (m *model) renderTasksView() string {
 		if m.showLineNumbers {
 			currentLineNumberWidth = lineNumberWidth
 		}
-		descAvailableWidth := contentWidth - lipgloss.Width(indentStr) - currentLineNumberWidth - statusRenderWidth - dateRenderWidth - timeRenderWidth - lipgloss.Width("   ")
+		descAvailableWidth := contentWidth - listItemStyle.GetHorizontalPadding() - lipgloss.Width(indentStr) - currentLineNumberWidth - priorityRenderWidth - statusRenderWidth - dateRenderWidth - dueRenderWidth - timeRenderWidth - lipgloss.Width("    ")
+		chips := renderTagChips(task.Tags)
+		if len(task.Tags) > 0 {
+			descAvailableWidth -= lipgloss.Width(chips) + 1
+		}
+		recurText := ""
+		if !task.Recur.IsZero() {
+			recurText = recurStyle.Render(recurMarker + task.Recur.String())
+			descAvailableWidth -= lipgloss.Width(recurText) + 1
+		}
+		treePrefix := strings.Repeat("  ", row.depth)
+		completionText := ""
+		if hasSubtasks {
+			if m.collapsed[task.ID] {
+				treePrefix += collapsedMarker
+			} else {
+				treePrefix += expandedMarker
+			}
+			done, total := m.completion(row.index)
+			completionText = completionStyle.Render(fmt.Sprintf(completionFormat, done*100/total))
+			descAvailableWidth -= lipgloss.Width(completionText) + 1
+		}
+		descAvailableWidth -= lipgloss.Width(treePrefix)
 		if descAvailableWidth < 5 {
 			descAvailableWidth = 5
 		}
 
-		descText := task.Description
-		if lipgloss.Width(descText) > descAvailableWidth {
-			runes := []rune(descText)
-			truncatedRunes := []rune{}
-			currentW := 0
-			for _, r := range runes {
-				runeW := lipgloss.Width(string(r))
-				if currentW+runeW > descAvailableWidth-lipgloss.Width("...") {
-					break
-				}
-				truncatedRunes = append(truncatedRunes, r)
-				currentW += runeW
-			}
-			descText = string(truncatedRunes) + "..."
+		descText := truncateText(task.Description, descAvailableWidth)
+		descriptionPart := treePrefix + descriptionStyle.Render(descText)
+		if completionText != "" {
+			descriptionPart += " " + completionText
+		}
+		if recurText != "" {
+			descriptionPart += " " + recurText
+		}
+		if len(task.Tags) > 0 {
+			descriptionPart += " " + chips
 		}
-		descriptionPart := descriptionStyle.Render(descText)
 
 		statusPartRender := lipgloss.NewStyle().Width(statusRenderWidth).Render(statusPart)
 		datePartRender := lipgloss.NewStyle().Width(dateRenderWidth).Align(lipgloss.Left).Render(datePart)
+		duePart := lipgloss.NewStyle().Width(dueRenderWidth).Render(m.renderDue(task, now))
 
-		lineContent := lipgloss.JoinHorizontal(lipgloss.Top, lineNumStr, statusPartRender, " ", datePartRender, " ", descriptionPart, " ", timePart)
+		lineContent := lipgloss.JoinHorizontal(lipgloss.Top, lineNumStr, priorityPart, statusPartRender, " ", datePartRender, " ", duePart, " ", descriptionPart, " ", timePart)
 
 		itemStyleToUse := listItemStyle.Copy()
 		if m.cursor == i {
@@ -670,6 +1642,24 @@ func (m *model) renderTasksView() string {
 	return strings.Join(taskLines, "\n")
 }
 
+// truncateText shortens text to fit in width columns, ending it with "...".
+func truncateText(text string, width int) string {
+	if lipgloss.Width(text) <= width {
+		return text
+	}
+	truncatedRunes := []rune{}
+	currentW := 0
+	for _, r := range text {
+		runeW := lipgloss.Width(string(r))
+		if currentW+runeW > width-lipgloss.Width("...") {
+			break
+		}
+		truncatedRunes = append(truncatedRunes, r)
+		currentW += runeW
+	}
+	return string(truncatedRunes) + "..."
+}
+
 func formatDuration(d time.Duration) string {
 	d = d.Round(time.Second)
 	h := d / time.Hour
@@ -685,14 +1675,82 @@ func generateHelp(km KeyMap, mode appMode) string {
 	if mode == modeViewTasks {
 		parts = []string{
 			km.Add.Help().Key + " " + km.Add.Help().Desc,
+			km.Edit.Help().Key + " " + km.Edit.Help().Desc,
 			km.Delete.Help().Key + " " + km.Delete.Help().Desc,
 			km.Up.Help().Key + "/" + km.Down.Help().Key + " " + helpNav,
 			km.Toggle.Help().Key + " " + km.Toggle.Help().Desc,
 			km.Complete.Help().Key + " " + km.Complete.Help().Desc,
+			km.Reopen.Help().Key + " " + km.Reopen.Help().Desc,
+			km.Undo.Help().Key + "/" + km.Redo.Help().Key + " " + helpUndo + "/" + helpRedo,
+			km.NextList.Help().Key + "/" + km.PrevList.Help().Key + " " + helpSwitchList,
+			km.NewList.Help().Key + " " + km.NewList.Help().Desc,
+			km.MoveTask.Help().Key + " " + km.MoveTask.Help().Desc,
+			km.RaisePriority.Help().Key + "/" + km.LowerPriority.Help().Key + " " + helpPriority,
+			km.Sort.Help().Key + " " + km.Sort.Help().Desc,
+			km.MoveUp.Help().Key + "/" + km.MoveDown.Help().Key + " " + helpMoveInOrder,
+			km.FilterTag.Help().Key + " " + km.FilterTag.Help().Desc,
+			km.Filter.Help().Key + " " + km.Filter.Help().Desc,
+			km.AddSubtask.Help().Key + " " + km.AddSubtask.Help().Desc,
+			km.Collapse.Help().Key + "/" + km.Expand.Help().Key + " " + helpCollapse,
+			km.PickBlocker.Help().Key + " " + km.PickBlocker.Help().Desc,
+			km.ClearBlockers.Help().Key + " " + km.ClearBlockers.Help().Desc,
+			km.ToggleDetail.Help().Key + " " + km.ToggleDetail.Help().Desc,
+			km.EditNotes.Help().Key + " " + km.EditNotes.Help().Desc,
+			km.Archive.Help().Key + " " + km.Archive.Help().Desc,
+			km.ArchiveView.Help().Key + " " + km.ArchiveView.Help().Desc,
+			km.Report.Help().Key + " " + km.Report.Help().Desc,
+			km.SetRate.Help().Key + " " + km.SetRate.Help().Desc,
 			km.ToggleLineNumbers.Help().Key + " " + km.ToggleLineNumbers.Help().Desc,
 			km.ToggleCalendar.Help().Key + " " + km.ToggleCalendar.Help().Desc,
 			km.Quit.Help().Key + " " + km.Quit.Help().Desc,
 		}
+	} else if mode == modeRecoverTimers {
+		parts = []string{
+			km.RecoverKeep.Help().Key + " " + km.RecoverKeep.Help().Desc,
+			km.RecoverEnd.Help().Key + " " + km.RecoverEnd.Help().Desc,
+			km.RecoverDiscard.Help().Key + " " + km.RecoverDiscard.Help().Desc,
+			km.Quit.Help().Key + " " + km.Quit.Help().Desc,
+		}
+	} else if mode == modeEditTask {
+		parts = []string{
+			km.Enter.Help().Key + " " + helpSave,
+			km.Esc.Help().Key + " " + km.Esc.Help().Desc,
+		}
+	} else if mode == modeFilter {
+		parts = []string{
+			km.Enter.Help().Key + " " + helpApply,
+			km.Esc.Help().Key + " " + helpClearFilter,
+		}
+	} else if mode == modeArchive {
+		parts = []string{
+			km.Up.Help().Key + "/" + km.Down.Help().Key + " " + helpNav,
+			km.Enter.Help().Key + " " + helpRestore,
+			km.Esc.Help().Key + " " + km.Esc.Help().Desc,
+		}
+	} else if mode == modeReport {
+		parts = []string{
+			km.PrevWeek.Help().Key + "/" + km.NextWeek.Help().Key + " " + helpWeek,
+			km.ScrollUp.Help().Key + "/" + km.ScrollDown.Help().Key + " " + helpScroll,
+			km.ToggleCalendar.Help().Key + " " + km.ToggleCalendar.Help().Desc,
+			km.Esc.Help().Key + " " + km.Esc.Help().Desc,
+		}
+	} else if mode == modeEditNotes {
+		parts = []string{
+			km.SaveNotes.Help().Key + " " + km.SaveNotes.Help().Desc,
+			km.Enter.Help().Key + " " + helpNewline,
+			km.Esc.Help().Key + " " + km.Esc.Help().Desc,
+		}
+	} else if mode == modePickBlocker {
+		parts = []string{
+			km.Up.Help().Key + "/" + km.Down.Help().Key + " " + helpChooseBlocker,
+			km.Enter.Help().Key + " " + km.Enter.Help().Desc,
+			km.Esc.Help().Key + " " + km.Esc.Help().Desc,
+		}
+	} else if mode == modeAddList || mode == modeMoveTask || mode == modeSetRate {
+		parts = []string{
+			km.Enter.Help().Key + " " + km.Enter.Help().Desc,
+			km.Esc.Help().Key + " " + km.Esc.Help().Desc,
+		}
 	} else { // modeAddTask
 		parts = []string{
 			km.Enter.Help().Key + " " + helpConfirmStay,
@@ -702,32 +1760,37 @@ func generateHelp(km KeyMap, mode appMode) string {
 	return strings.Join(parts, " │ ")
 }
 
-func saveTasksToFile(filename string, tasks []Task) error {
-	data, err := json.MarshalIndent(tasks, "", "  ")
+func saveTasksToFile(filename string, doc tasksDocument) error {
+	doc.Version = currentSchemaVersion
+	data, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
 		return fmt.Errorf(errorMarshal, err)
 	}
-	err = ioutil.WriteFile(filename, data, 0644)
+	err = writeFileAtomic(filename, data, 0644)
 	if err != nil {
 		return fmt.Errorf(errorWriteTasks, err)
 	}
 	return nil
 }
 
-func loadTasksFromFile(filename string) ([]Task, error) {
-	data, err := ioutil.ReadFile(filename)
+// loadTasksFromFile reads and decodes the tasks file, upgrading it in
+// memory only. It also returns the version the file was written in.
+func loadTasksFromFile(filename string) (tasksDocument, int, error) {
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []Task{}, err
+			return tasksDocument{Tasks: []Task{}}, currentSchemaVersion, err
 		}
-		return nil, fmt.Errorf(errorReadTasksFile, err)
+		return tasksDocument{}, 0, fmt.Errorf(errorReadTasksFile, err)
 	}
-	var tasks []Task
-	err = json.Unmarshal(data, &tasks)
+	doc, version, err := decodeTasksDocument(data)
 	if err != nil {
-		return nil, fmt.Errorf(errorUnmarshalTasks, err)
+		return tasksDocument{}, version, fmt.Errorf(errorUnmarshalTasks, err)
+	}
+	for i := range doc.Tasks {
+		doc.Tasks[i].recalcTimeSpent()
 	}
-	return tasks, nil
+	return doc, version, nil
 }
 
 // Helper for max(int, int)
@@ -739,9 +1802,44 @@ func max(a, b int) int {
 }
 
 func main() {
+	fileFlag := flag.String("file", "", flagFileUsage)
+	archiveAfterFlag := flag.Int("archive-after", 0, flagArchiveAfterUsage)
+	flag.Usage = func() { printUsage(os.Stderr) }
+	flag.Parse()
+
 	// tea.LogToFile("debug.log", "debug")
-	program := tea.NewProgram(initialModel(), tea.WithAltScreen(), tea.WithMouseCellMotion())
-	if _, err := program.Run(); err != nil {
+	tasksFilename, err := resolveTasksFilename(*fileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, errorOpeningTasksLog, err)
+		os.Exit(1)
+	}
+	archiveAfter, err := resolveArchiveAfter(*archiveAfterFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	store, err := openStore(tasksFilename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, errorOpeningTasksLog, err)
+		os.Exit(1)
+	}
+	archive, err := openStore(archiveFilename(tasksFilename))
+	if err != nil {
+		store.Close()
+		fmt.Fprintf(os.Stderr, errorOpeningTasksLog, err)
+		os.Exit(1)
+	}
+	if flag.NArg() > 0 {
+		code := runCommand(store, archive, flag.Args())
+		store.Close()
+		archive.Close()
+		os.Exit(code)
+	}
+	program := tea.NewProgram(initialModel(store, archive, archiveAfter), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	_, err = program.Run()
+	store.Close()
+	archive.Close()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, errorRunningProgram, err)
 		os.Exit(1)
 	}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// outputFormat selects how list-like commands print their results.
+type outputFormat string
+
+const (
+	outputTable  outputFormat = "table"
+	outputJSON   outputFormat = "json"
+	outputNDJSON outputFormat = "ndjson"
+)
+
+// addOutputFlag registers the -output flag on a command's flag set.
+func addOutputFlag(flags *flag.FlagSet) *string {
+	return flags.String("output", string(outputTable), flagOutputUsage)
+}
+
+func parseOutputFormat(value string) (outputFormat, error) {
+	switch format := outputFormat(value); format {
+	case outputTable, outputJSON, outputNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf(errorUnknownOutput, value)
+	}
+}
+
+// taskRecord is the machine-readable form of a task. Field names are part
+// of the CLI's interface; don't rename them.
+type taskRecord struct {
+	Index            int       `json:"index"`
+	ID               string    `json:"id"`
+	Description      string    `json:"description"`
+	Status           string    `json:"status"`
+	List             string    `json:"list"`
+	Priority         string    `json:"priority"`
+	TimeSpentSeconds int64     `json:"time_spent_seconds"`
+	CreatedAt        time.Time `json:"created_at"`
+	Due              time.Time `json:"due,omitzero"`
+	CompletedAt      time.Time `json:"completed_at,omitzero"`
+	Tags             []string  `json:"tags"`
+	ParentID         string    `json:"parent_id,omitempty"`
+	BlockedBy        []string  `json:"blocked_by,omitempty"`
+	Recur            string    `json:"recur,omitempty"`
+	Notes            string    `json:"notes,omitempty"`
+	Rate             string    `json:"rate,omitempty"`
+}
+
+func newTaskRecord(index int, task Task, now time.Time) taskRecord {
+	record := taskRecord{
+		Index:            index,
+		ID:               task.ID.String(),
+		Description:      task.Description,
+		Status:           taskStatusNames[task.Status],
+		List:             listDisplayName(task.List),
+		Priority:         priorityNames[task.Priority],
+		TimeSpentSeconds: int64(task.elapsed(now).Seconds()),
+		CreatedAt:        task.CreatedAt,
+		Due:              task.Due,
+		CompletedAt:      task.CompletedAt,
+		Tags:             append([]string{}, task.Tags...),
+		Recur:            task.Recur.String(),
+		Notes:            task.Notes,
+		Rate:             task.Rate.String(),
+	}
+	if task.ParentID != uuid.Nil {
+		record.ParentID = task.ParentID.String()
+	}
+	for _, id := range task.BlockedBy {
+		record.BlockedBy = append(record.BlockedBy, id.String())
+	}
+	return record
+}
+
+// timerRecord describes the running timer.
+type timerRecord struct {
+	taskRecord
+	RunningSince          time.Time `json:"running_since"`
+	RunningSessionSeconds int64     `json:"running_session_seconds"`
+}
+
+// totalRecord is one row of `gotodo totals`.
+type totalRecord struct {
+	Group            string `json:"group,omitempty"`
+	Tasks            int    `json:"tasks"`
+	Completed        int    `json:"completed"`
+	TimeSpentSeconds int64  `json:"time_spent_seconds"`
+}
+
+// totalsReport is the full `gotodo totals` result.
+type totalsReport struct {
+	By     string        `json:"by"`
+	Groups []totalRecord `json:"groups"`
+	Total  totalRecord   `json:"total"`
+}
+
+// newTotalsReport sums tasks into the given groups, in order. A task whose
+// group isn't listed gets a group of its own at the end. A task counts once
+// towards each of its groups and once towards the total.
+func newTotalsReport(by string, groups []string, tasks []Task, groupsOf func(Task) []string, now time.Time) totalsReport {
+	report := totalsReport{By: by, Groups: []totalRecord{}}
+	index := make(map[string]int, len(groups))
+	for _, group := range groups {
+		if _, ok := index[group]; !ok {
+			index[group] = len(report.Groups)
+			report.Groups = append(report.Groups, totalRecord{Group: group})
+		}
+	}
+	for _, task := range tasks {
+		var groupRows []int
+		for _, group := range groupsOf(task) {
+			i, ok := index[group]
+			if !ok {
+				i = len(report.Groups)
+				index[group] = i
+				report.Groups = append(report.Groups, totalRecord{Group: group})
+			}
+			groupRows = append(groupRows, i)
+		}
+		rows := []*totalRecord{&report.Total}
+		for _, i := range groupRows {
+			rows = append(rows, &report.Groups[i])
+		}
+		for _, row := range rows {
+			row.Tasks++
+			if task.Status == Completed {
+				row.Completed++
+			}
+			row.TimeSpentSeconds += int64(task.elapsed(now).Seconds())
+		}
+	}
+	return report
+}
+
+// writeJSON prints v as a single indented JSON document.
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeNDJSON prints one compact JSON object per line.
+func writeNDJSON[T any](out io.Writer, records []T) error {
+	encoder := json.NewEncoder(out)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// resolveTasksFilename decides where tasks are stored, in order of
+// precedence: the --file flag, $GOTODO_FILE, then the XDG data directory.
+// The containing directory is created if needed.
+func resolveTasksFilename(fileFlag string) (string, error) {
+	filename := fileFlag
+	if filename == "" {
+		filename = os.Getenv("GOTODO_FILE")
+	}
+	if filename == "" {
+		var err error
+		if filename, err = defaultTasksFilename(); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", fmt.Errorf(errorCreateDataDir, err)
+	}
+	return filename, nil
+}
+
+func defaultTasksFilename() (string, error) {
+	name := "gotodo.json"
+	if os.Getenv("GOTODO_BACKEND") == "sqlite" {
+		name = "gotodo.db"
+	}
+
+	dataDir, err := xdgDir("XDG_DATA_HOME", ".local", "share")
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dataDir, "gotodo", name)
+
+	// Older versions kept tasks in the config directory; move them over
+	// the first time the new location is used.
+	configDir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", err
+	}
+	legacy := filepath.Join(configDir, name)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+				return "", fmt.Errorf(errorCreateDataDir, err)
+			}
+			if err := os.Rename(legacy, filename); err != nil {
+				// e.g. across filesystems: keep using the old file.
+				return legacy, nil
+			}
+		}
+	}
+	return filename, nil
+}
+
+// xdgDir returns the directory named by the XDG variable env, falling back
+// to the given path under the user's home directory.
+func xdgDir(env string, fallback ...string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf(errorHomeDir, err)
+	}
+	return filepath.Join(append([]string{homeDir}, fallback...)...), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import "fmt"
+
+// Priority ranks how important a task is. The zero value is no priority.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+// priorityNames are the stable names priorities are stored under.
+var priorityNames = map[Priority]string{
+	PriorityNone:   "none",
+	PriorityLow:    "low",
+	PriorityMedium: "medium",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+func parsePriority(name string) (Priority, error) {
+	for priority, priorityName := range priorityNames {
+		if priorityName == name {
+			return priority, nil
+		}
+	}
+	return 0, fmt.Errorf(errorUnknownPriority, name)
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	name, ok := priorityNames[p]
+	if !ok {
+		return nil, fmt.Errorf(errorUnknownPriority, int(p))
+	}
+	return []byte(name), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	priority, err := parsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
+// marker is the short label shown in the priority column of the task list.
+func (p Priority) marker() string {
+	switch p {
+	case PriorityLow:
+		return priorityLowMarker
+	case PriorityMedium:
+		return priorityMediumMarker
+	case PriorityHigh:
+		return priorityHighMarker
+	case PriorityUrgent:
+		return priorityUrgentMarker
+	default:
+		return ""
+	}
+}
+
+// changePriority raises or lowers the selected task's priority by delta,
+// staying within none..urgent. The cursor follows the task if the list is
+// sorted by priority.
+func (m *model) changePriority(delta int) {
+	i, ok := m.selectedTask()
+	if !ok {
+		return
+	}
+	priority := Priority(min(max(int(m.tasks[i].Priority)+delta, int(PriorityNone)), int(PriorityUrgent)))
+	if priority == m.tasks[i].Priority {
+		return
+	}
+	m.checkpoint()
+	m.tasks[i].Priority = priority
+	m.saveTask(i)
+	m.selectTask(m.tasks[i].ID)
+}
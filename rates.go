@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// notBillableRate is how a rate that bills nothing is typed and stored.
+const notBillableRate = "none"
+
+var ratePattern = regexp.MustCompile(`^(\d+)(?:\.(\d{1,2}))?\s*([a-z]{3})?$`)
+
+// Rate is an hourly rate in cents of a currency. A task, tag or list can
+// also be marked not billable, which stops a rate further out from applying.
+type Rate struct {
+	Cents       int64
+	Currency    string // ISO 4217 code, or "" when only one is used
+	NotBillable bool
+}
+
+func (r Rate) IsZero() bool {
+	return r.Cents == 0 && !r.NotBillable
+}
+
+// billable reports whether time at this rate is billed.
+func (r Rate) billable() bool {
+	return r.Cents > 0
+}
+
+// String writes the rate the way it is typed, such as "95.00 EUR". It is
+// also the stored form.
+func (r Rate) String() string {
+	switch {
+	case r.NotBillable:
+		return notBillableRate
+	case r.IsZero():
+		return ""
+	default:
+		return formatMoney(r.Cents, r.Currency)
+	}
+}
+
+// parseRate reads an amount with an optional currency code, such as "95",
+// "95.50 eur" or "120usd", or "none" for time that isn't billed.
+func parseRate(text string) (Rate, error) {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	if lower == notBillableRate {
+		return Rate{NotBillable: true}, nil
+	}
+	match := ratePattern.FindStringSubmatch(lower)
+	if match == nil {
+		return Rate{}, fmt.Errorf(errorParseRate, text)
+	}
+	units, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return Rate{}, fmt.Errorf(errorParseRate, text)
+	}
+	cents, _ := strconv.ParseInt((match[2] + "00")[:2], 10, 64)
+	rate := Rate{Cents: units*100 + cents, Currency: strings.ToUpper(match[3])}
+	if rate.Cents == 0 {
+		return Rate{NotBillable: true}, nil
+	}
+	return rate, nil
+}
+
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*r = Rate{}
+		return nil
+	}
+	parsed, err := parseRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// bill returns what d is billed at this rate, in cents.
+func (r Rate) bill(d time.Duration) int64 {
+	if !r.billable() {
+		return 0
+	}
+	return int64(math.Round(float64(r.Cents) * d.Hours()))
+}
+
+func formatMoney(cents int64, currency string) string {
+	money := fmt.Sprintf("%d.%02d", cents/100, cents%100)
+	if currency != "" {
+		money += " " + currency
+	}
+	return money
+}
+
+// Rates are the hourly rates set on lists and tags. A task's own rate wins
+// over its tags', which win over its list's.
+type Rates struct {
+	Lists map[string]Rate `json:"lists,omitempty"`
+	Tags  map[string]Rate `json:"tags,omitempty"`
+}
+
+// rateFor returns the rate the task's time is billed at.
+func (r Rates) rateFor(task Task) Rate {
+	if !task.Rate.IsZero() {
+		return task.Rate
+	}
+	for _, tag := range task.Tags {
+		if rate, ok := r.Tags[tag]; ok {
+			return rate
+		}
+	}
+	return r.Lists[task.List]
+}
+
+// amounts are money totals by currency, in cents, since rates in different
+// currencies can't be added up.
+type amounts map[string]int64
+
+func (a amounts) add(currency string, cents int64) {
+	if cents != 0 {
+		a[currency] += cents
+	}
+}
+
+func (a amounts) String() string {
+	currencies := make([]string, 0, len(a))
+	for currency := range a {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+	parts := make([]string, len(currencies))
+	for i, currency := range currencies {
+		parts[i] = formatMoney(a[currency], currency)
+	}
+	return strings.Join(parts, " + ")
+}
+
+// startRateInput opens the input box to set the selected task's own rate.
+func (m *model) startRateInput() tea.Cmd {
+	i, ok := m.selectedTask()
+	if !ok {
+		return nil
+	}
+	m.mode = modeSetRate
+	m.input.SetValue(m.tasks[i].Rate.String())
+	m.input.CursorEnd()
+	m.input.Placeholder = ratePlaceholder
+	m.input.Focus()
+	m.helpMsg = generateHelp(m.keyMap, modeSetRate)
+	return textinput.Blink
+}
+
+// stopRateInput closes the rate input, saving the rate if save is set. An
+// empty rate goes back to the tag's or list's.
+func (m *model) stopRateInput(save bool) {
+	if i, ok := m.selectedTask(); save && ok {
+		rate, err := parseRate(m.input.Value())
+		if strings.TrimSpace(m.input.Value()) == "" {
+			rate, err = Rate{}, nil
+		}
+		if err != nil {
+			m.err = err
+			return
+		}
+		if rate != m.tasks[i].Rate {
+			m.checkpoint()
+			m.tasks[i].Rate = rate
+			m.saveTask(i)
+		}
+	}
+	m.mode = modeViewTasks
+	m.input.Blur()
+	m.input.SetValue("")
+	m.input.Placeholder = inputPlaceholder
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Rate
+		wantErr bool
+	}{
+		{"95", Rate{Cents: 9500}, false},
+		{"95.5", Rate{Cents: 9550}, false},
+		{"95.50 eur", Rate{Cents: 9550, Currency: "EUR"}, false},
+		{"120USD", Rate{Cents: 12000, Currency: "USD"}, false},
+		{" 0.05 gbp ", Rate{Cents: 5, Currency: "GBP"}, false},
+		{"none", Rate{NotBillable: true}, false},
+		{"None", Rate{NotBillable: true}, false},
+		{"0", Rate{NotBillable: true}, false},
+		{"95.505", Rate{}, true},
+		{"-10", Rate{}, true},
+		{"95 euro", Rate{}, true},
+		{"", Rate{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRate(tt.text)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRate(%q) = %+v, %v; want %+v, error %v", tt.text, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRateStringRoundTrip(t *testing.T) {
+	for _, text := range []string{"95.00", "95.50 EUR", "0.05 GBP", "none"} {
+		rate, err := parseRate(text)
+		if err != nil {
+			t.Fatalf("parseRate(%q): %v", text, err)
+		}
+		if got := rate.String(); got != text {
+			t.Errorf("parseRate(%q).String() = %q", text, got)
+		}
+	}
+}
+
+func TestRateBill(t *testing.T) {
+	tests := []struct {
+		rate Rate
+		d    time.Duration
+		want int64
+	}{
+		{Rate{Cents: 9000}, time.Hour, 9000},
+		{Rate{Cents: 9000}, 20 * time.Minute, 3000},
+		{Rate{Cents: 100}, 30 * time.Second, 1}, // 0.83 rounds up
+		{Rate{Cents: 100}, 10 * time.Second, 0},
+		{Rate{NotBillable: true}, time.Hour, 0},
+		{Rate{}, time.Hour, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.rate.bill(tt.d); got != tt.want {
+			t.Errorf("%+v.bill(%s) = %d, want %d", tt.rate, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// recurKeyword introduces a repeat rule in the add and edit input, as in
+// "Weekly review every:fri".
+const recurKeyword = "every:"
+
+var (
+	ordinalPattern  = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)$`)
+	intervalPattern = regexp.MustCompile(`^(\d+)([dw])$`)
+)
+
+type recurrenceKind int
+
+const (
+	recurNone recurrenceKind = iota
+	recurDaily
+	recurWeekdays
+	recurWeekly
+	recurMonthly
+	recurInterval
+)
+
+// Recurrence is a repeat rule. Completing a task that has one spawns the
+// next instance; the completed task keeps its time and loses the rule.
+type Recurrence struct {
+	Kind    recurrenceKind
+	Weekday time.Weekday // for recurWeekly
+	Day     int          // day of the month for recurMonthly
+	Days    int          // days after completion for recurInterval
+}
+
+func (r Recurrence) IsZero() bool {
+	return r.Kind == recurNone
+}
+
+// String writes the rule the way it is typed after every:. It is also the
+// stored form, so never change one; add a schema migration instead.
+func (r Recurrence) String() string {
+	switch r.Kind {
+	case recurDaily:
+		return "daily"
+	case recurWeekdays:
+		return "weekdays"
+	case recurWeekly:
+		return strings.ToLower(r.Weekday.String()[:3])
+	case recurMonthly:
+		return strconv.Itoa(r.Day) + ordinalSuffix(r.Day)
+	case recurInterval:
+		return strconv.Itoa(r.Days) + "d"
+	default:
+		return ""
+	}
+}
+
+func ordinalSuffix(n int) string {
+	if n%100 >= 11 && n%100 <= 13 {
+		return "th"
+	}
+	switch n % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	default:
+		return "th"
+	}
+}
+
+// parseRecurrence reads "daily", "weekdays", a weekday name for weekly,
+// a day of the month such as "15th" for monthly, or "3d" and "2w" for
+// that long after each completion.
+func parseRecurrence(rule string) (Recurrence, error) {
+	lower := strings.ToLower(rule)
+	switch lower {
+	case "daily", "day":
+		return Recurrence{Kind: recurDaily}, nil
+	case "weekdays", "weekday":
+		return Recurrence{Kind: recurWeekdays}, nil
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if lower == name || lower == name[:3] {
+			return Recurrence{Kind: recurWeekly, Weekday: weekday}, nil
+		}
+	}
+	if match := ordinalPattern.FindStringSubmatch(lower); match != nil {
+		day, _ := strconv.Atoi(match[1])
+		if day >= 1 && day <= 31 {
+			return Recurrence{Kind: recurMonthly, Day: day}, nil
+		}
+	}
+	if match := intervalPattern.FindStringSubmatch(lower); match != nil {
+		days, err := strconv.Atoi(match[1])
+		if match[2] == "w" {
+			days *= 7
+		}
+		if err == nil && days > 0 {
+			return Recurrence{Kind: recurInterval, Days: days}, nil
+		}
+	}
+	return Recurrence{}, fmt.Errorf(errorParseRecur, rule)
+}
+
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*r = Recurrence{}
+		return nil
+	}
+	parsed, err := parseRecurrence(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// nextDay returns the first day after the local midnight from that the rule
+// falls on. Monthly rules fall on the last day of months that are too short.
+func (r Recurrence) nextDay(from time.Time) time.Time {
+	switch r.Kind {
+	case recurWeekdays:
+		day := from.AddDate(0, 0, 1)
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day
+	case recurWeekly:
+		return from.AddDate(0, 0, (int(r.Weekday)-int(from.Weekday())+6)%7+1)
+	case recurMonthly:
+		year, month, _ := from.Date()
+		for {
+			lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, from.Location()).Day()
+			day := time.Date(year, month, min(r.Day, lastDay), 0, 0, 0, 0, from.Location())
+			if day.After(from) {
+				return day
+			}
+			month++
+		}
+	case recurInterval:
+		return from.AddDate(0, 0, r.Days)
+	default:
+		return from.AddDate(0, 0, 1)
+	}
+}
+
+// firstDue is when a new task with the rule and no due date of its own is
+// first due: the rule's next day counting today, or nothing for rules that
+// count from a completion.
+func (r Recurrence) firstDue(now time.Time) time.Time {
+	if r.Kind == recurNone || r.Kind == recurInterval {
+		return time.Time{}
+	}
+	return r.nextDay(startOfDay(now).AddDate(0, 0, -1))
+}
+
+// nextRecurrence moves the task's repeat rule onto a fresh copy of it, due
+// on the rule's next day after the current due date, or after today if that
+// has passed. Interval rules count from today. A due time of day is kept.
+func (t *Task) nextRecurrence(now time.Time) (Task, bool) {
+	rule := t.Recur
+	if rule.IsZero() {
+		return Task{}, false
+	}
+	from := startOfDay(now)
+	if rule.Kind != recurInterval && !t.Due.IsZero() && startOfDay(t.Due).After(from) {
+		from = startOfDay(t.Due)
+	}
+	due := rule.nextDay(from)
+	if !t.Due.IsZero() {
+		due = due.Add(t.Due.Sub(startOfDay(t.Due)))
+	}
+	t.Recur = Recurrence{}
+	return Task{
+		ID:          uuid.New(),
+		Description: t.Description,
+		Status:      Pending,
+		CreatedAt:   now,
+		List:        t.List,
+		Priority:    t.Priority,
+		Due:         due,
+		Tags:        slices.Clone(t.Tags),
+		Position:    t.Position,
+		ParentID:    t.ParentID,
+		Recur:       rule,
+	}, true
+}
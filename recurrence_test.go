@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    Recurrence
+		wantErr bool
+	}{
+		{"daily", Recurrence{Kind: recurDaily}, false},
+		{"Day", Recurrence{Kind: recurDaily}, false},
+		{"weekdays", Recurrence{Kind: recurWeekdays}, false},
+		{"fri", Recurrence{Kind: recurWeekly, Weekday: time.Friday}, false},
+		{"Monday", Recurrence{Kind: recurWeekly, Weekday: time.Monday}, false},
+		{"1st", Recurrence{Kind: recurMonthly, Day: 1}, false},
+		{"31st", Recurrence{Kind: recurMonthly, Day: 31}, false},
+		{"3d", Recurrence{Kind: recurInterval, Days: 3}, false},
+		{"2w", Recurrence{Kind: recurInterval, Days: 14}, false},
+		{"32nd", Recurrence{}, true},
+		{"0th", Recurrence{}, true},
+		{"0d", Recurrence{}, true},
+		{"monthly", Recurrence{}, true},
+		{"", Recurrence{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRecurrence(tt.rule)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRecurrence(%q) = %+v, %v; want %+v, error %v", tt.rule, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRecurrenceStringRoundTrip(t *testing.T) {
+	for _, rule := range []string{"daily", "weekdays", "sun", "1st", "2nd", "3rd", "11th", "22nd", "5d"} {
+		parsed, err := parseRecurrence(rule)
+		if err != nil {
+			t.Fatalf("parseRecurrence(%q): %v", rule, err)
+		}
+		if got := parsed.String(); got != rule {
+			t.Errorf("parseRecurrence(%q).String() = %q", rule, got)
+		}
+	}
+}
+
+func TestRecurrenceNextDay(t *testing.T) {
+	tests := []struct {
+		rule string
+		from time.Time
+		want time.Time
+	}{
+		{"daily", day(2025, 10, 15), day(2025, 10, 16)},
+		{"daily", day(2025, 12, 31), day(2026, 1, 1)},
+		{"weekdays", day(2025, 10, 16), day(2025, 10, 17)}, // Thursday
+		{"weekdays", day(2025, 10, 17), day(2025, 10, 20)}, // Friday
+		{"weekdays", day(2025, 10, 18), day(2025, 10, 20)}, // Saturday
+		{"wed", day(2025, 10, 15), day(2025, 10, 22)},      // never the same day
+		{"fri", day(2025, 10, 15), day(2025, 10, 17)},
+		{"15th", day(2025, 10, 14), day(2025, 10, 15)},
+		{"15th", day(2025, 10, 15), day(2025, 11, 15)},
+		{"31st", day(2025, 10, 31), day(2025, 11, 30)}, // short month
+		{"31st", day(2024, 1, 31), day(2024, 2, 29)},   // leap year
+		{"30th", day(2025, 12, 30), day(2026, 1, 30)},
+		{"3d", day(2025, 10, 15), day(2025, 10, 18)},
+		{"2w", day(2025, 10, 15), day(2025, 10, 29)},
+	}
+	for _, tt := range tests {
+		rule, err := parseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("parseRecurrence(%q): %v", tt.rule, err)
+		}
+		if got := rule.nextDay(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s.nextDay(%s) = %s, want %s", tt.rule, tt.from.Format(time.DateOnly), got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// reportColumnWidth fits a "Mon 10/13" day heading or an "00:00:00" time,
+// with a space.
+const reportColumnWidth = 10
+
+// reportRow is the time tracked on each day of the week for one task or
+// tag, and what it bills.
+type reportRow struct {
+	Label  string
+	Days   [7]time.Duration
+	Amount amounts
+}
+
+func (r reportRow) total() time.Duration {
+	var total time.Duration
+	for _, d := range r.Days {
+		total += d
+	}
+	return total
+}
+
+func (r *reportRow) add(other reportRow) {
+	for day, d := range other.Days {
+		r.Days[day] += d
+	}
+	if r.Amount == nil {
+		r.Amount = amounts{}
+	}
+	for currency, cents := range other.Amount {
+		r.Amount.add(currency, cents)
+	}
+}
+
+// weekReport is the time tracked in the week starting at Start, per task
+// and per tag, with only the tasks and tags that have time that week.
+type weekReport struct {
+	Start time.Time
+	Tasks []reportRow
+	Tags  []reportRow
+	Total reportRow
+}
+
+// trackedSessions returns the task's sessions, with a running timer as one
+// that ends now.
+func (t Task) trackedSessions(now time.Time) []Session {
+	if t.Status == InProgress && !t.LastStartedAt.IsZero() {
+		return append(slices.Clip(t.Sessions), Session{Start: t.LastStartedAt, End: now})
+	}
+	return t.Sessions
+}
+
+// weekTime splits the task's tracked time over the days of the week starting
+// at start, cutting sessions that run past midnight.
+func (t Task) weekTime(start, now time.Time) [7]time.Duration {
+	var days [7]time.Duration
+	for _, session := range t.trackedSessions(now) {
+		for day := range days {
+			dayStart, dayEnd := start.AddDate(0, 0, day), start.AddDate(0, 0, day+1)
+			from, to := session.Start, session.End
+			if from.Before(dayStart) {
+				from = dayStart
+			}
+			if to.After(dayEnd) {
+				to = dayEnd
+			}
+			if to.After(from) {
+				days[day] += to.Sub(from)
+			}
+		}
+	}
+	return days
+}
+
+// newWeekReport totals the time tracked on tasks in the week starting at
+// start, and what it bills at rates. Tasks are listed by most time first,
+// tags alphabetically. Each task counts only its own time, so subtasks
+// aren't counted twice.
+func newWeekReport(tasks []Task, rates Rates, start, now time.Time) weekReport {
+	report := weekReport{Start: start}
+	tags := map[string]*reportRow{}
+	for _, task := range tasks {
+		row := reportRow{Label: task.Description, Days: task.weekTime(start, now), Amount: amounts{}}
+		if row.total() == 0 {
+			continue
+		}
+		rate := rates.rateFor(task)
+		row.Amount.add(rate.Currency, rate.bill(row.total()))
+		report.Tasks = append(report.Tasks, row)
+		report.Total.add(row)
+		taskTags := task.Tags
+		if len(taskTags) == 0 {
+			taskTags = []string{untaggedGroup}
+		}
+		for _, tag := range taskTags {
+			if tags[tag] == nil {
+				tags[tag] = &reportRow{Label: tag}
+			}
+			tags[tag].add(row)
+		}
+	}
+	slices.SortStableFunc(report.Tasks, func(a, b reportRow) int { return cmp.Compare(b.total(), a.total()) })
+	for _, row := range tags {
+		report.Tags = append(report.Tags, *row)
+	}
+	slices.SortFunc(report.Tags, func(a, b reportRow) int { return cmp.Compare(a.Label, b.Label) })
+	return report
+}
+
+// reportStart is the first day of the week the report shows.
+func (m model) reportStart(now time.Time) time.Time {
+	return startOfWeek(now, m.useJalaliCalendar).AddDate(0, 0, -7*m.reportWeek)
+}
+
+// loadReportWeek queries the archive for the tasks with time in the week the
+// report shows. Tasks still in the list come from m.tasks instead, where a
+// running timer's time is counted too.
+func (m *model) loadReportWeek() {
+	start := m.reportStart(time.Now())
+	archived, err := m.archive.Query(TaskQuery{From: start, To: start.AddDate(0, 0, 7)})
+	if err != nil {
+		m.err = err
+	}
+	m.reportArchived = archived
+}
+
+// startReportView switches the list to the report for the current week.
+func (m *model) startReportView() {
+	m.mode = modeReport
+	m.reportWeek = 0
+	m.loadReportWeek()
+	m.viewport.SetYOffset(0)
+	m.helpMsg = generateHelp(m.keyMap, modeReport)
+	m.updateLayout()
+}
+
+// stopReportView goes back to the task list.
+func (m *model) stopReportView() {
+	m.mode = modeViewTasks
+	m.viewport.SetYOffset(0)
+	m.helpMsg = generateHelp(m.keyMap, modeViewTasks)
+	m.updateLayout()
+}
+
+// reportIndicatorText names the week the report shows, for the line under
+// the stats bar.
+func (m model) reportIndicatorText() string {
+	start := m.reportStart(time.Now())
+	return fmt.Sprintf(reportIndicator, formatTimestamp(start, m.useJalaliCalendar), formatTimestamp(start.AddDate(0, 0, 6), m.useJalaliCalendar))
+}
+
+// renderReportView shows the time tracked each day of the week, across all
+// lists and the archive, per task and then per tag. Once rates are set, a
+// last column shows what the time bills.
+func (m model) renderReportView() string {
+	now := time.Now()
+	tasks := append(slices.Clip(m.tasks), m.reportArchived...)
+	report := newWeekReport(tasks, m.rates, m.reportStart(now), now)
+
+	amountWidth := 0
+	if len(report.Total.Amount) > 0 {
+		amountWidth = lipgloss.Width(reportAmount)
+		for _, row := range slices.Concat(report.Tasks, report.Tags, []reportRow{report.Total}) {
+			amountWidth = max(amountWidth, lipgloss.Width(row.Amount.String()))
+		}
+		amountWidth += 2
+	}
+	contentWidth := m.viewport.Width - taskViewportStyle.GetHorizontalFrameSize()
+	labelWidth := max(5, contentWidth-listItemStyle.GetHorizontalPadding()-8*reportColumnWidth-amountWidth)
+	line := func(label string, cells []string) string {
+		text := lipgloss.NewStyle().Width(labelWidth).Render(truncateText(label, labelWidth-1))
+		for i, cell := range cells {
+			width := reportColumnWidth
+			if i == 8 {
+				width = amountWidth
+			}
+			text += lipgloss.NewStyle().Width(width).Align(lipgloss.Right).Render(cell)
+		}
+		return listItemStyle.Width(contentWidth).MaxHeight(1).Render(text)
+	}
+	times := func(row reportRow) []string {
+		cells := make([]string, 0, len(row.Days)+2)
+		for _, d := range row.Days {
+			if d == 0 {
+				cells = append(cells, reportNoTime)
+			} else {
+				cells = append(cells, formatDuration(d))
+			}
+		}
+		cells = append(cells, formatDuration(row.total()))
+		if amountWidth > 0 {
+			cells = append(cells, cmp.Or(row.Amount.String(), reportNoTime))
+		}
+		return cells
+	}
+	heading := func(label string) string {
+		cells := make([]string, 0, 9)
+		for day := range 7 {
+			date := report.Start.AddDate(0, 0, day)
+			cells = append(cells, date.Weekday().String()[:3]+" "+monthDay(date, m.useJalaliCalendar))
+		}
+		cells = append(cells, reportTotal)
+		if amountWidth > 0 {
+			cells = append(cells, reportAmount)
+		}
+		return reportHeadingStyle.Render(line(label, cells))
+	}
+
+	if len(report.Tasks) == 0 {
+		return line(reportNothing, nil)
+	}
+	lines := []string{heading(reportTasks)}
+	for _, row := range report.Tasks {
+		lines = append(lines, line(row.Label, times(row)))
+	}
+	lines = append(lines, reportHeadingStyle.Render(line(reportTotal, times(report.Total))), "", heading(reportTags))
+	for _, row := range report.Tags {
+		lines = append(lines, line(row.Label, times(row)))
+	}
+	return strings.Join(lines, "\n")
+}
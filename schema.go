@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// currentSchemaVersion is the version saveTasksToFile writes. Bump it and
+// append to schemaMigrations whenever the on-disk shape of Task changes.
+const currentSchemaVersion = 13
+
+// tasksDocument is the top-level shape of the tasks file. Files written
+// before versioning are a bare array of tasks and count as version 0.
+type tasksDocument struct {
+	Version int      `json:"version"`
+	Lists   []string `json:"lists"` // named lists, in tab order
+	Tasks   []Task   `json:"tasks"`
+	Rates   Rates    `json:"rates,omitzero"`
+}
+
+// schemaMigrations[i] upgrades a decoded document from version i to i+1.
+// They work on generic JSON so they keep reading old files correctly no
+// matter how Task changes later.
+var schemaMigrations = []func(doc map[string]any) error{
+	migrateTimeSpentToSessions,
+	migrateStatusesToNames,
+	migrateAddLists,
+	migrateNothing, // priority
+	migrateNothing, // due
+	migrateNothing, // tags
+	migrateNothing, // position
+	migrateNothing, // parent_id
+	migrateNothing, // blocked_by and the blocked status
+	migrateNothing, // recur
+	migrateNothing, // notes
+	migrateNothing, // completed_at
+	migrateNothing, // rate and the top-level rates
+}
+
+// decodeTasksDocument parses a tasks file of any known version, upgrading it
+// to currentSchemaVersion. It also returns the version it was written in.
+func decodeTasksDocument(data []byte) (tasksDocument, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // keep nanosecond durations exact
+
+	var raw any
+	if err := decoder.Decode(&raw); err != nil {
+		return tasksDocument{}, 0, err
+	}
+
+	var doc map[string]any
+	switch v := raw.(type) {
+	case []any:
+		doc = map[string]any{"version": json.Number("0"), "tasks": v}
+	case map[string]any:
+		doc = v
+	default:
+		return tasksDocument{}, 0, errors.New(errorMalformedTasks)
+	}
+
+	versionNumber, ok := doc["version"].(json.Number)
+	if !ok {
+		return tasksDocument{}, 0, errors.New(errorMalformedTasks)
+	}
+	version64, err := versionNumber.Int64()
+	if err != nil {
+		return tasksDocument{}, 0, errors.New(errorMalformedTasks)
+	}
+	version := int(version64)
+	if version > currentSchemaVersion {
+		return tasksDocument{}, version, fmt.Errorf(errorNewerSchema, version)
+	}
+
+	for v := version; v < currentSchemaVersion; v++ {
+		if err := schemaMigrations[v](doc); err != nil {
+			return tasksDocument{}, version, fmt.Errorf(errorMigrateSchema, v, v+1, err)
+		}
+	}
+	doc["version"] = currentSchemaVersion
+
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return tasksDocument{}, version, err
+	}
+	var result tasksDocument
+	if err := json.Unmarshal(upgraded, &result); err != nil {
+		return tasksDocument{}, version, err
+	}
+	if result.Tasks == nil {
+		result.Tasks = []Task{}
+	}
+	return result, version, nil
+}
+
+// backupTasksFile keeps a copy of a file about to be upgraded from version.
+// An existing backup of the same version is left alone, since it is the
+// closer to the user's original data.
+func backupTasksFile(filename string, version int) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	backupName := fmt.Sprintf("%s.v%d.bak", filename, version)
+	f, err := os.OpenFile(backupName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func documentTasks(doc map[string]any) ([]map[string]any, error) {
+	list, ok := doc["tasks"].([]any)
+	if !ok {
+		if doc["tasks"] == nil {
+			return nil, nil
+		}
+		return nil, errors.New(errorMalformedTasks)
+	}
+	tasks := make([]map[string]any, len(list))
+	for i, item := range list {
+		task, ok := item.(map[string]any)
+		if !ok {
+			return nil, errors.New(errorMalformedTasks)
+		}
+		tasks[i] = task
+	}
+	return tasks, nil
+}
+
+// migrateTimeSpentToSessions upgrades tasks saved before sessions existed:
+// the old accumulated time_spent becomes a single synthetic session starting
+// at the task's creation time.
+func migrateTimeSpentToSessions(doc map[string]any) error {
+	tasks, err := documentTasks(doc)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if sessions, ok := task["sessions"].([]any); ok && len(sessions) > 0 {
+			continue
+		}
+		spentNumber, ok := task["time_spent"].(json.Number)
+		if !ok {
+			continue
+		}
+		spent, err := spentNumber.Int64()
+		if err != nil || spent <= 0 {
+			continue
+		}
+		createdText, _ := task["created_at"].(string)
+		createdAt, err := time.Parse(time.RFC3339Nano, createdText)
+		if err != nil {
+			return err
+		}
+		task["sessions"] = []any{map[string]any{
+			"start": createdAt.Format(time.RFC3339Nano),
+			"end":   createdAt.Add(time.Duration(spent)).Format(time.RFC3339Nano),
+			"note":  sessionMigratedNote,
+		}}
+	}
+	return nil
+}
+
+// migrateStatusesToNames replaces the integer statuses of version 1 with
+// their stable names.
+func migrateStatusesToNames(doc map[string]any) error {
+	// The TaskStatus order as of version 1.
+	legacyStatuses := []string{"pending", "in_progress", "paused", "completed"}
+
+	tasks, err := documentTasks(doc)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		statusNumber, ok := task["status"].(json.Number)
+		if !ok {
+			continue // already a name
+		}
+		status, err := statusNumber.Int64()
+		if err != nil || status < 0 || int(status) >= len(legacyStatuses) {
+			return fmt.Errorf(errorUnknownStatus, statusNumber)
+		}
+		task["status"] = legacyStatuses[status]
+	}
+	return nil
+}
+
+// migrateAddLists introduces named lists. Existing tasks have no "list" and
+// so stay in the default list.
+func migrateAddLists(doc map[string]any) error {
+	if _, ok := doc["lists"]; !ok {
+		doc["lists"] = []any{}
+	}
+	return nil
+}
+
+// migrateNothing upgrades across a version that only added optional task
+// fields. Older files don't have them, which decodes to their zero values,
+// but the version still has to change so that an older Gotodo refuses the
+// file rather than dropping the new fields when it saves.
+func migrateNothing(doc map[string]any) error {
+	return nil
+}
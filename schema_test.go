@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDecodeTasksDocument(t *testing.T) {
+	created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		wantStatus  TaskStatus
+		wantLists   []string
+		wantSession *Session
+		wantErr     bool
+	}{
+		{
+			name:        "v0 bare array with accumulated time",
+			data:        `[{"id":"12345678-0000-4000-8000-000000000000","description":"a","status":2,"time_spent":3600000000000,"created_at":"2024-01-01T10:00:00Z"}]`,
+			wantVersion: 0,
+			wantStatus:  Paused,
+			wantLists:   []string{},
+			wantSession: &Session{Start: created, End: created.Add(time.Hour), Note: sessionMigratedNote},
+		},
+		{
+			name:        "v1 integer statuses",
+			data:        `{"version":1,"tasks":[{"id":"12345678-0000-4000-8000-000000000000","description":"a","status":3,"created_at":"2024-01-01T10:00:00Z","sessions":[]}]}`,
+			wantVersion: 1,
+			wantStatus:  Completed,
+			wantLists:   []string{},
+		},
+		{
+			name:        "v2 without lists",
+			data:        `{"version":2,"tasks":[{"id":"12345678-0000-4000-8000-000000000000","description":"a","status":"in_progress","created_at":"2024-01-01T10:00:00Z","sessions":[]}]}`,
+			wantVersion: 2,
+			wantStatus:  InProgress,
+			wantLists:   []string{},
+		},
+		{
+			name:        "v3 with lists",
+			data:        `{"version":3,"lists":["Work"],"tasks":[{"id":"12345678-0000-4000-8000-000000000000","description":"a","status":"pending","created_at":"2024-01-01T10:00:00Z","sessions":[]}]}`,
+			wantVersion: 3,
+			wantStatus:  Pending,
+			wantLists:   []string{"Work"},
+		},
+		{
+			name:        "current",
+			data:        fmt.Sprintf(`{"version":%d,"lists":[],"tasks":[{"id":"12345678-0000-4000-8000-000000000000","description":"a","status":"pending","created_at":"2024-01-01T10:00:00Z","sessions":[],"priority":"high"}]}`, currentSchemaVersion),
+			wantVersion: currentSchemaVersion,
+			wantStatus:  Pending,
+			wantLists:   []string{},
+		},
+		{name: "newer version", data: `{"version":99,"tasks":[]}`, wantErr: true},
+		{name: "missing version", data: `{"tasks":[]}`, wantErr: true},
+		{name: "not a document", data: `"tasks"`, wantErr: true},
+		{name: "unknown legacy status", data: `{"version":1,"tasks":[{"status":7}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, version, err := decodeTasksDocument([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", version, tt.wantVersion)
+			}
+			if doc.Version != currentSchemaVersion {
+				t.Errorf("doc.Version = %d, want %d", doc.Version, currentSchemaVersion)
+			}
+			if !slices.Equal(doc.Lists, tt.wantLists) {
+				t.Errorf("lists = %q, want %q", doc.Lists, tt.wantLists)
+			}
+			if len(doc.Tasks) != 1 {
+				t.Fatalf("got %d tasks, want 1", len(doc.Tasks))
+			}
+			task := doc.Tasks[0]
+			if task.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", task.Status, tt.wantStatus)
+			}
+			if tt.wantSession != nil {
+				if len(task.Sessions) != 1 {
+					t.Fatalf("got %d sessions, want 1", len(task.Sessions))
+				}
+				got := task.Sessions[0]
+				if !got.Start.Equal(tt.wantSession.Start) || !got.End.Equal(tt.wantSession.End) || got.Note != tt.wantSession.Note {
+					t.Errorf("session = %+v, want %+v", got, *tt.wantSession)
+				}
+			}
+		})
+	}
+}
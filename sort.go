@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sortOrder is how the task list is ordered. The sort key cycles through
+// them in this order.
+type sortOrder int
+
+const (
+	sortCreated sortOrder = iota
+	sortStatus
+	sortTimeSpent
+	sortPriority
+	sortDue
+	sortAlphabetical
+	sortManual
+	sortOrderCount
+)
+
+func (o sortOrder) String() string {
+	switch o {
+	case sortStatus:
+		return sortStatusLabel
+	case sortTimeSpent:
+		return sortTimeSpentLabel
+	case sortPriority:
+		return sortPriorityLabel
+	case sortDue:
+		return sortDueLabel
+	case sortAlphabetical:
+		return sortAlphabeticalLabel
+	case sortManual:
+		return sortManualLabel
+	default:
+		return sortCreatedLabel
+	}
+}
+
+// statusSortRank puts running work first and finished work last.
+var statusSortRank = map[TaskStatus]int{
+	InProgress: 0,
+	Paused:     1,
+	Pending:    2,
+	Completed:  3,
+}
+
+// sortTasks orders task indices by order. Ties, and the created order
+// itself, put the newest task first.
+func sortTasks(tasks []Task, indices []int, order sortOrder, now time.Time) {
+	slices.SortStableFunc(indices, func(a, b int) int {
+		ta, tb := tasks[a], tasks[b]
+		var c int
+		switch order {
+		case sortStatus:
+			c = cmp.Compare(statusSortRank[ta.Status], statusSortRank[tb.Status])
+		case sortTimeSpent:
+			c = cmp.Compare(tb.elapsed(now), ta.elapsed(now))
+		case sortPriority:
+			c = cmp.Compare(tb.Priority, ta.Priority)
+		case sortDue:
+			switch {
+			case ta.Due.IsZero() && tb.Due.IsZero():
+			case ta.Due.IsZero():
+				c = 1
+			case tb.Due.IsZero():
+				c = -1
+			default:
+				c = ta.Due.Compare(tb.Due)
+			}
+		case sortAlphabetical:
+			c = cmp.Compare(strings.ToLower(ta.Description), strings.ToLower(tb.Description))
+		case sortManual:
+			c = cmp.Compare(ta.Position, tb.Position)
+		}
+		if c != 0 {
+			return c
+		}
+		return tb.CreatedAt.Compare(ta.CreatedAt)
+	})
+}
+
+// cycleSortOrder moves on to the next sort order, keeping the cursor on the
+// selected task.
+func (m *model) cycleSortOrder() {
+	selected, ok := m.selectedTask()
+	m.sortOrder = (m.sortOrder + 1) % sortOrderCount
+	if ok {
+		m.selectTask(m.tasks[selected].ID)
+	}
+}
+
+// moveSelectedInOrder swaps the selected task with its neighbouring sibling
+// in the manual order, delta being -1 for up and 1 for down. Positions in
+// the current list are renumbered first, so tasks never placed by hand (at
+// position 0) keep their place and every task gets its own position.
+func (m *model) moveSelectedInOrder(delta int) {
+	if m.sortOrder != sortManual {
+		return
+	}
+	rows := m.visibleRows()
+	if m.cursor >= len(rows) {
+		return
+	}
+	target := -1
+	for j := m.cursor + delta; j >= 0 && j < len(rows) && rows[j].depth >= rows[m.cursor].depth; j += delta {
+		if rows[j].depth == rows[m.cursor].depth {
+			target = j
+			break
+		}
+	}
+	if target < 0 {
+		return
+	}
+	m.checkpoint()
+
+	var list []int
+	for i, task := range m.tasks {
+		if task.List == m.currentList() {
+			list = append(list, i)
+		}
+	}
+	sortTasks(m.tasks, list, sortManual, time.Now())
+	renumbered := map[int]bool{}
+	for position, i := range list {
+		if m.tasks[i].Position != position+1 {
+			m.tasks[i].Position = position + 1
+			renumbered[i] = true
+		}
+	}
+
+	a, b := rows[m.cursor].index, rows[target].index
+	m.tasks[a].Position, m.tasks[b].Position = m.tasks[b].Position, m.tasks[a].Position
+	renumbered[a], renumbered[b] = true, true
+	for i := range renumbered {
+		m.saveTask(i)
+	}
+	m.selectTask(m.tasks[a].ID)
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations upgrade the database schema in order; PRAGMA user_version
+// records how many have been applied.
+var sqliteMigrations = []string{
+	`CREATE TABLE tasks (
+		id              TEXT PRIMARY KEY,
+		description     TEXT NOT NULL,
+		status          INTEGER NOT NULL,
+		last_started_at INTEGER,
+		created_at      INTEGER NOT NULL,
+		last_saved_at   INTEGER
+	);
+	CREATE TABLE sessions (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id  TEXT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		start_at INTEGER NOT NULL,
+		end_at   INTEGER NOT NULL,
+		note     TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX sessions_task_id ON sessions(task_id);
+	CREATE INDEX sessions_start_at ON sessions(start_at);`,
+	// Store statuses by name so reordering TaskStatus can't corrupt them.
+	`ALTER TABLE tasks ADD COLUMN status_name TEXT NOT NULL DEFAULT 'pending';
+	UPDATE tasks SET status_name = CASE status
+		WHEN 1 THEN 'in_progress'
+		WHEN 2 THEN 'paused'
+		WHEN 3 THEN 'completed'
+		ELSE 'pending'
+	END;
+	ALTER TABLE tasks DROP COLUMN status;
+	ALTER TABLE tasks RENAME COLUMN status_name TO status;`,
+	`ALTER TABLE tasks ADD COLUMN list TEXT NOT NULL DEFAULT '';
+	CREATE TABLE lists (name TEXT PRIMARY KEY);`,
+	`ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT 'none';`,
+	`ALTER TABLE tasks ADD COLUMN due_at INTEGER;`,
+	// Tags can't contain spaces, so they are kept space-separated.
+	`ALTER TABLE tasks ADD COLUMN tags TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE tasks ADD COLUMN position INTEGER NOT NULL DEFAULT 0;`,
+	`ALTER TABLE tasks ADD COLUMN parent_id TEXT NOT NULL DEFAULT '';`,
+	// Blockers are kept as space-separated task IDs, like tags.
+	`ALTER TABLE tasks ADD COLUMN blocked_by TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE tasks ADD COLUMN recur TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE tasks ADD COLUMN notes TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE tasks ADD COLUMN completed_at INTEGER;`,
+	// Rates are stored in their typed form, such as "95.00 EUR" or "none".
+	`ALTER TABLE tasks ADD COLUMN rate TEXT NOT NULL DEFAULT '';
+	CREATE TABLE rates (
+		kind TEXT NOT NULL, -- 'list' or 'tag'
+		name TEXT NOT NULL,
+		rate TEXT NOT NULL,
+		PRIMARY KEY (kind, name)
+	);`,
+}
+
+// sqliteStore keeps tasks and sessions in an embedded SQLite database so that
+// long histories load quickly and can be queried by time range. Like the
+// tasks file, the database is locked: the TUI writes back whole tasks from
+// its own copy, so only the instance holding the lock may write.
+type sqliteStore struct {
+	db       *sql.DB
+	lock     *fileLock
+	readOnly bool
+}
+
+func openSQLiteStore(filename string) (*sqliteStore, error) {
+	s := &sqliteStore{}
+	lock, err := acquireLock(filename)
+	if errors.Is(err, errFileLocked) {
+		s.readOnly = true
+	} else if err != nil {
+		return nil, err
+	}
+	s.lock = lock
+
+	db, err := sql.Open("sqlite", "file:"+filename+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf(errorOpenDatabase, err)
+	}
+	s.db = db
+	if err := s.migrate(); err != nil {
+		s.Close()
+		return nil, fmt.Errorf(errorMigrateDatabase, err)
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database is missing. A read-only
+// instance leaves that to the lock holder.
+func (s *sqliteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if s.readOnly && version < len(sqliteMigrations) {
+		return errReadOnly
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Times are stored as Unix nanoseconds; NULL stands for the zero time.
+func toUnixNano(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// storedID stores uuid.Nil as "" rather than as all zeros.
+func storedID(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
+
+// storedIDs joins ids with spaces.
+func storedIDs(ids []uuid.UUID) string {
+	fields := make([]string, len(ids))
+	for i, id := range ids {
+		fields[i] = id.String()
+	}
+	return strings.Join(fields, " ")
+}
+
+func fromUnixNano(n sql.NullInt64) time.Time {
+	if !n.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, n.Int64)
+}
+
+func (s *sqliteStore) Load() ([]Task, error) {
+	return s.Query(TaskQuery{})
+}
+
+func (s *sqliteStore) Query(q TaskQuery) ([]Task, error) {
+	var taskWhere, sessionWhere []string
+	var taskArgs, sessionArgs []any
+
+	if len(q.Statuses) > 0 {
+		placeholders := make([]string, len(q.Statuses))
+		for i, status := range q.Statuses {
+			placeholders[i] = "?"
+			taskArgs = append(taskArgs, taskStatusNames[status])
+		}
+		taskWhere = append(taskWhere, "t.status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if q.Text != "" {
+		taskWhere = append(taskWhere, "t.description LIKE ? ESCAPE '\\'")
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q.Text)
+		taskArgs = append(taskArgs, "%"+escaped+"%")
+	}
+	if !q.From.IsZero() {
+		sessionWhere = append(sessionWhere, "s.end_at > ?")
+		sessionArgs = append(sessionArgs, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		sessionWhere = append(sessionWhere, "s.start_at < ?")
+		sessionArgs = append(sessionArgs, q.To.UnixNano())
+	}
+	windowed := len(sessionWhere) > 0
+	if windowed {
+		taskWhere = append(taskWhere, "EXISTS (SELECT 1 FROM sessions s WHERE s.task_id = t.id AND "+strings.Join(sessionWhere, " AND ")+")")
+		taskArgs = append(taskArgs, sessionArgs...)
+	}
+
+	taskQuery := `SELECT t.id, t.description, t.status, t.last_started_at, t.created_at, t.last_saved_at, t.list, t.priority, t.due_at, t.tags, t.position, t.parent_id, t.blocked_by, t.recur, t.notes, t.completed_at, t.rate FROM tasks t`
+	if len(taskWhere) > 0 {
+		taskQuery += " WHERE " + strings.Join(taskWhere, " AND ")
+	}
+	taskQuery += " ORDER BY t.created_at DESC"
+
+	rows, err := s.db.Query(taskQuery, taskArgs...)
+	if err != nil {
+		return nil, fmt.Errorf(errorQueryDatabase, err)
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	byID := map[uuid.UUID]int{}
+	for rows.Next() {
+		var (
+			task                                  Task
+			id                                    string
+			status, priority, tags, parentID      string
+			blockedBy, recur, rate                string
+			lastStartedAt, createdAt, lastSavedAt sql.NullInt64
+			dueAt, completedAt                    sql.NullInt64
+		)
+		if err := rows.Scan(&id, &task.Description, &status, &lastStartedAt, &createdAt, &lastSavedAt, &task.List, &priority, &dueAt, &tags, &task.Position, &parentID, &blockedBy, &recur, &task.Notes, &completedAt, &rate); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		if task.ID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		if task.Status, err = parseTaskStatus(status); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		if task.Priority, err = parsePriority(priority); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		task.LastStartedAt = fromUnixNano(lastStartedAt)
+		task.CreatedAt = fromUnixNano(createdAt)
+		task.LastSavedAt = fromUnixNano(lastSavedAt)
+		task.Due = fromUnixNano(dueAt)
+		task.CompletedAt = fromUnixNano(completedAt)
+		task.Tags = strings.Fields(tags)
+		if parentID != "" {
+			if task.ParentID, err = uuid.Parse(parentID); err != nil {
+				return nil, fmt.Errorf(errorQueryDatabase, err)
+			}
+		}
+		for _, field := range strings.Fields(blockedBy) {
+			blocker, err := uuid.Parse(field)
+			if err != nil {
+				return nil, fmt.Errorf(errorQueryDatabase, err)
+			}
+			task.BlockedBy = append(task.BlockedBy, blocker)
+		}
+		if err := task.Recur.UnmarshalText([]byte(recur)); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		if err := task.Rate.UnmarshalText([]byte(rate)); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		byID[task.ID] = len(tasks)
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(errorQueryDatabase, err)
+	}
+
+	sessionQuery := `SELECT s.task_id, s.start_at, s.end_at, s.note FROM sessions s`
+	if windowed {
+		sessionQuery += " WHERE " + strings.Join(sessionWhere, " AND ")
+	}
+	sessionQuery += " ORDER BY s.start_at"
+
+	sessionRows, err := s.db.Query(sessionQuery, sessionArgs...)
+	if err != nil {
+		return nil, fmt.Errorf(errorQueryDatabase, err)
+	}
+	defer sessionRows.Close()
+
+	for sessionRows.Next() {
+		var (
+			taskID     string
+			session    Session
+			start, end sql.NullInt64
+		)
+		if err := sessionRows.Scan(&taskID, &start, &end, &session.Note); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		id, err := uuid.Parse(taskID)
+		if err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		i, ok := byID[id]
+		if !ok {
+			continue
+		}
+		session.Start = fromUnixNano(start)
+		session.End = fromUnixNano(end)
+		tasks[i].Sessions = append(tasks[i].Sessions, session)
+	}
+	if err := sessionRows.Err(); err != nil {
+		return nil, fmt.Errorf(errorQueryDatabase, err)
+	}
+
+	for i := range tasks {
+		tasks[i].recalcTimeSpent()
+	}
+	return tasks, nil
+}
+
+// sqlExecer is a *sql.DB or a *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (s *sqliteStore) UpsertTask(task Task) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	return upsertTask(s.db, task)
+}
+
+func upsertTask(db sqlExecer, task Task) error {
+	_, err := db.Exec(`INSERT INTO tasks (id, description, status, last_started_at, created_at, last_saved_at, list, priority, due_at, tags, position, parent_id, blocked_by, recur, notes, completed_at, rate)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			description = excluded.description,
+			status = excluded.status,
+			last_started_at = excluded.last_started_at,
+			created_at = excluded.created_at,
+			last_saved_at = excluded.last_saved_at,
+			list = excluded.list,
+			priority = excluded.priority,
+			due_at = excluded.due_at,
+			tags = excluded.tags,
+			position = excluded.position,
+			parent_id = excluded.parent_id,
+			blocked_by = excluded.blocked_by,
+			recur = excluded.recur,
+			notes = excluded.notes,
+			completed_at = excluded.completed_at,
+			rate = excluded.rate`,
+		task.ID.String(), task.Description, taskStatusNames[task.Status],
+		toUnixNano(task.LastStartedAt), toUnixNano(task.CreatedAt), toUnixNano(task.LastSavedAt),
+		task.List, priorityNames[task.Priority], toUnixNano(task.Due),
+		strings.Join(task.Tags, " "), task.Position, storedID(task.ParentID), storedIDs(task.BlockedBy), task.Recur.String(), task.Notes, toUnixNano(task.CompletedAt), task.Rate.String(),
+	)
+	if err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) DeleteTask(id uuid.UUID) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	if _, err := s.db.Exec(`DELETE FROM tasks WHERE id = ?`, id.String()); err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	return nil
+}
+
+// ImportTask writes the task and all its sessions in one transaction.
+func (s *sqliteStore) ImportTask(task Task) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	defer tx.Rollback() // no-op once committed
+	if err := upsertTask(tx, task); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE task_id = ?`, task.ID.String()); err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	for _, session := range task.Sessions {
+		if err := appendSession(tx, task.ID, session); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) AppendSession(id uuid.UUID, session Session) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	return appendSession(s.db, id, session)
+}
+
+func appendSession(db sqlExecer, id uuid.UUID, session Session) error {
+	_, err := db.Exec(`INSERT INTO sessions (task_id, start_at, end_at, note) VALUES (?, ?, ?, ?)`,
+		id.String(), session.Start.UnixNano(), session.End.UnixNano(), session.Note,
+	)
+	if err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Lists() ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM lists ORDER BY rowid`)
+	if err != nil {
+		return nil, fmt.Errorf(errorQueryDatabase, err)
+	}
+	defer rows.Close()
+
+	var lists []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf(errorQueryDatabase, err)
+		}
+		lists = append(lists, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf(errorQueryDatabase, err)
+	}
+	return lists, nil
+}
+
+func (s *sqliteStore) AddList(name string) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	if _, err := s.db.Exec(`INSERT OR IGNORE INTO lists (name) VALUES (?)`, name); err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Rates() (Rates, error) {
+	rows, err := s.db.Query(`SELECT kind, name, rate FROM rates`)
+	if err != nil {
+		return Rates{}, fmt.Errorf(errorQueryDatabase, err)
+	}
+	defer rows.Close()
+
+	var rates Rates
+	for rows.Next() {
+		var kind, name, text string
+		if err := rows.Scan(&kind, &name, &text); err != nil {
+			return Rates{}, fmt.Errorf(errorQueryDatabase, err)
+		}
+		rate, err := parseRate(text)
+		if err != nil {
+			return Rates{}, fmt.Errorf(errorQueryDatabase, err)
+		}
+		if kind == "tag" {
+			rates.Tags = setRate(rates.Tags, name, rate)
+		} else {
+			rates.Lists = setRate(rates.Lists, name, rate)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Rates{}, fmt.Errorf(errorQueryDatabase, err)
+	}
+	return rates, nil
+}
+
+func (s *sqliteStore) SetListRate(name string, rate Rate) error {
+	return s.setRate("list", name, rate)
+}
+
+func (s *sqliteStore) SetTagRate(tag string, rate Rate) error {
+	return s.setRate("tag", tag, rate)
+}
+
+func (s *sqliteStore) setRate(kind, name string, rate Rate) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	var err error
+	if rate.IsZero() {
+		_, err = s.db.Exec(`DELETE FROM rates WHERE kind = ? AND name = ?`, kind, name)
+	} else {
+		_, err = s.db.Exec(`INSERT INTO rates (kind, name, rate) VALUES (?, ?, ?)
+			ON CONFLICT(kind, name) DO UPDATE SET rate = excluded.rate`, kind, name, rate.String())
+	}
+	if err != nil {
+		return fmt.Errorf(errorWriteDatabase, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) ReadOnly() bool {
+	return s.readOnly
+}
+
+func (s *sqliteStore) Close() error {
+	var err error
+	if s.db != nil {
+		err = s.db.Close()
+	}
+	if s.lock != nil {
+		if lockErr := s.lock.release(); err == nil {
+			err = lockErr
+		}
+		s.lock = nil
+	}
+	return err
+}
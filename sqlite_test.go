@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSQLiteStoreLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tasks.db")
+	first, err := openSQLiteStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := Task{ID: uuid.New(), Description: "a", CreatedAt: time.Now()}
+	if err := first.UpsertTask(task); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := openSQLiteStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ReadOnly() || !second.ReadOnly() {
+		t.Fatalf("ReadOnly() = %v, %v; want false, true", first.ReadOnly(), second.ReadOnly())
+	}
+	if tasks, err := second.Load(); err != nil || len(tasks) != 1 {
+		t.Errorf("read-only Load() = %d tasks, %v; want 1", len(tasks), err)
+	}
+	if err := second.UpsertTask(task); !errors.Is(err, errReadOnly) {
+		t.Errorf("read-only UpsertTask() = %v, want %v", err, errReadOnly)
+	}
+	if err := second.AppendSession(task.ID, Session{Start: time.Now(), End: time.Now()}); !errors.Is(err, errReadOnly) {
+		t.Errorf("read-only AppendSession() = %v, want %v", err, errReadOnly)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the read-only store must not release the writer's lock.
+	third, err := openSQLiteStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !third.ReadOnly() {
+		t.Error("the lock was released by a read-only instance")
+	}
+	third.Close()
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fourth, err := openSQLiteStore(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fourth.Close()
+	if fourth.ReadOnly() {
+		t.Error("the lock was kept after the writer closed")
+	}
+}
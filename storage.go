@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	errFileLocked     = errors.New(errorFileLocked)
+	errReadOnly       = errors.New(errorReadOnly)
+	errExternalChange = errors.New(errorExternalChange)
+)
+
+// fileLock is an advisory lock on the tasks file, implemented as a sibling
+// ".lock" file holding the owner's pid.
+type fileLock struct {
+	path string
+}
+
+func acquireLock(filename string) (*fileLock, error) {
+	lockPath := filename + ".lock"
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.WriteString(strconv.Itoa(os.Getpid()))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(lockPath)
+				return nil, fmt.Errorf(errorCreateLock, err)
+			}
+			return &fileLock{path: lockPath}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf(errorCreateLock, err)
+		}
+
+		data, err := os.ReadFile(lockPath)
+		if err != nil {
+			return nil, fmt.Errorf(errorCreateLock, err)
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && (pid == os.Getpid() || processAlive(pid)) {
+			return nil, fmt.Errorf("%w (pid %d)", errFileLocked, pid)
+		}
+		// The owner is gone; the lock is stale.
+		if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf(errorCreateLock, err)
+		}
+	}
+	return nil, errFileLocked
+}
+
+func (l *fileLock) release() error {
+	return os.Remove(l.path)
+}
+
+// writeFileAtomic replaces filename with data so that readers only ever see
+// the old or the new contents, never a partial write.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+
+	// Persist the rename itself. Directories can't be synced on every
+	// platform, so failures here are not fatal.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// fileFingerprint identifies a version of the tasks file on disk.
+type fileFingerprint struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+func fingerprintFile(filename string) (fileFingerprint, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileFingerprint{}, nil
+		}
+		return fileFingerprint{}, err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fileFingerprint{}, err
+	}
+	return fileFingerprint{modTime: info.ModTime(), size: info.Size(), sum: sha256.Sum256(data)}, nil
+}
+
+// sameContents reports whether two fingerprints describe the same data. A
+// changed mtime alone (e.g. from touch) is not treated as a modification.
+func (f fileFingerprint) sameContents(other fileFingerprint) bool {
+	if f.size != other.size {
+		return false
+	}
+	if f.modTime.Equal(other.modTime) {
+		return true
+	}
+	return bytes.Equal(f.sum[:], other.sum[:])
+}
+
+// taskFile is the tasks file opened by this process. Only the instance
+// holding the lock may write; any other instance opens it read-only. A file
+// that exists but couldn't be loaded is never written either, so a newer or
+// damaged file isn't replaced by the empty document loaded in its place.
+type taskFile struct {
+	path        string
+	lock        *fileLock
+	readOnly    bool
+	loadErr     error
+	fingerprint fileFingerprint
+}
+
+func openTaskFile(filename string) (*taskFile, error) {
+	f := &taskFile{path: filename}
+	lock, err := acquireLock(filename)
+	if errors.Is(err, errFileLocked) {
+		f.readOnly = true
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.lock = lock
+	return f, nil
+}
+
+func (f *taskFile) Load() (tasksDocument, error) {
+	doc, version, err := loadTasksFromFile(f.path)
+	// Only the lock holder rewrites an older file; a read-only instance
+	// works on the upgraded copy in memory.
+	if err == nil && version < currentSchemaVersion && !f.readOnly {
+		if err = f.upgrade(doc, version); err != nil {
+			doc = tasksDocument{}
+		}
+	}
+	f.loadErr = nil
+	if err != nil && !os.IsNotExist(err) {
+		f.loadErr = err
+	}
+	if fp, fpErr := fingerprintFile(f.path); fpErr == nil {
+		f.fingerprint = fp
+	}
+	return doc, err
+}
+
+// upgrade rewrites the file, written in version, as doc in the current
+// version, keeping a backup of the original.
+func (f *taskFile) upgrade(doc tasksDocument, version int) error {
+	if err := backupTasksFile(f.path, version); err != nil {
+		return fmt.Errorf(errorBackupTasks, err)
+	}
+	return saveTasksToFile(f.path, doc)
+}
+
+func (f *taskFile) Save(doc tasksDocument) error {
+	if f.readOnly {
+		return errReadOnly
+	}
+	if f.loadErr != nil {
+		return fmt.Errorf(errorNotLoaded, f.loadErr)
+	}
+	current, err := fingerprintFile(f.path)
+	if err != nil {
+		return fmt.Errorf(errorReadTasksFile, err)
+	}
+	if !current.sameContents(f.fingerprint) {
+		return errExternalChange
+	}
+	if err := saveTasksToFile(f.path, doc); err != nil {
+		return err
+	}
+	if fp, err := fingerprintFile(f.path); err == nil {
+		f.fingerprint = fp
+	}
+	return nil
+}
+
+func (f *taskFile) Close() error {
+	if f.lock == nil {
+		return nil
+	}
+	err := f.lock.release()
+	f.lock = nil
+	return err
+}
+
+// Store persists tasks for the model, which keeps its own working copy and
+// reports each change as it happens. Tracked time is append-only: UpsertTask
+// saves a task's own fields, and sessions are only ever added with
+// AppendSession. ImportTask is the exception, writing a task together with
+// its sessions in one go as it moves between stores.
+type Store interface {
+	Load() ([]Task, error)
+	UpsertTask(task Task) error
+	DeleteTask(id uuid.UUID) error
+	AppendSession(id uuid.UUID, session Session) error
+	ImportTask(task Task) error
+	Query(q TaskQuery) ([]Task, error)
+	Lists() ([]string, error)
+	AddList(name string) error
+	Rates() (Rates, error)
+	SetListRate(name string, rate Rate) error
+	SetTagRate(tag string, rate Rate) error
+	ReadOnly() bool
+	Close() error
+}
+
+// TaskQuery selects tasks from a Store. Zero fields match everything. When
+// From or To is set, only tasks with sessions overlapping that window are
+// returned, carrying just those sessions.
+type TaskQuery struct {
+	Statuses []TaskStatus
+	Text     string // case-insensitive substring of the description
+	From, To time.Time
+}
+
+func (q TaskQuery) matchesTask(task Task) bool {
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, task.Status) {
+		return false
+	}
+	if q.Text != "" && !strings.Contains(strings.ToLower(task.Description), strings.ToLower(q.Text)) {
+		return false
+	}
+	return true
+}
+
+func (q TaskQuery) matchesSession(s Session) bool {
+	if !q.From.IsZero() && !s.End.After(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !s.Start.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// openStore picks a backend from the file extension: SQLite for .db,
+// .sqlite and .sqlite3 files, JSON for anything else.
+func openStore(filename string) (Store, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".db", ".sqlite", ".sqlite3":
+		s, err := openSQLiteStore(filename)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		s, err := openJSONStore(filename)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+}
+
+// jsonStore keeps the whole document in memory and rewrites the tasks file
+// on every change.
+type jsonStore struct {
+	file *taskFile
+	doc  tasksDocument
+}
+
+func openJSONStore(filename string) (*jsonStore, error) {
+	file, err := openTaskFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonStore{file: file}, nil
+}
+
+func (s *jsonStore) Load() ([]Task, error) {
+	doc, err := s.file.Load()
+	s.doc = doc
+	return cloneTasks(doc.Tasks), err
+}
+
+func (s *jsonStore) indexOf(id uuid.UUID) int {
+	return slices.IndexFunc(s.doc.Tasks, func(t Task) bool { return t.ID == id })
+}
+
+func (s *jsonStore) UpsertTask(task Task) error {
+	task = cloneTask(task)
+	if i := s.indexOf(task.ID); i >= 0 {
+		task.Sessions = s.doc.Tasks[i].Sessions
+		task.recalcTimeSpent()
+		s.doc.Tasks[i] = task
+	} else {
+		task.Sessions = nil
+		task.recalcTimeSpent()
+		s.doc.Tasks = append([]Task{task}, s.doc.Tasks...)
+	}
+	return s.file.Save(s.doc)
+}
+
+func (s *jsonStore) DeleteTask(id uuid.UUID) error {
+	i := s.indexOf(id)
+	if i < 0 {
+		return nil
+	}
+	doc := s.doc
+	doc.Tasks = slices.Delete(slices.Clone(s.doc.Tasks), i, i+1)
+	return s.save(doc)
+}
+
+func (s *jsonStore) ImportTask(task Task) error {
+	task = cloneTask(task)
+	task.recalcTimeSpent()
+	doc := s.doc
+	if i := s.indexOf(task.ID); i >= 0 {
+		doc.Tasks = slices.Clone(s.doc.Tasks)
+		doc.Tasks[i] = task
+	} else {
+		doc.Tasks = append([]Task{task}, s.doc.Tasks...)
+	}
+	return s.save(doc)
+}
+
+// save writes doc and keeps it only once it is written, so a failed move
+// between stores leaves the task where it was.
+func (s *jsonStore) save(doc tasksDocument) error {
+	if err := s.file.Save(doc); err != nil {
+		return err
+	}
+	s.doc = doc
+	return nil
+}
+
+func (s *jsonStore) AppendSession(id uuid.UUID, session Session) error {
+	i := s.indexOf(id)
+	if i < 0 {
+		return fmt.Errorf(errorTaskNotFound, id)
+	}
+	s.doc.Tasks[i].Sessions = append(s.doc.Tasks[i].Sessions, session)
+	s.doc.Tasks[i].recalcTimeSpent()
+	return s.file.Save(s.doc)
+}
+
+func (s *jsonStore) Query(q TaskQuery) ([]Task, error) {
+	var result []Task
+	windowed := !q.From.IsZero() || !q.To.IsZero()
+	for _, task := range s.doc.Tasks {
+		if !q.matchesTask(task) {
+			continue
+		}
+		task = cloneTask(task)
+		if windowed {
+			task.Sessions = slices.DeleteFunc(task.Sessions, func(s Session) bool { return !q.matchesSession(s) })
+			if len(task.Sessions) == 0 {
+				continue
+			}
+			task.recalcTimeSpent()
+		}
+		result = append(result, task)
+	}
+	return result, nil
+}
+
+func (s *jsonStore) Lists() ([]string, error) {
+	return slices.Clone(s.doc.Lists), nil
+}
+
+func (s *jsonStore) AddList(name string) error {
+	if slices.Contains(s.doc.Lists, name) {
+		return nil
+	}
+	s.doc.Lists = append(s.doc.Lists, name)
+	return s.file.Save(s.doc)
+}
+
+func (s *jsonStore) Rates() (Rates, error) {
+	return Rates{Lists: maps.Clone(s.doc.Rates.Lists), Tags: maps.Clone(s.doc.Rates.Tags)}, nil
+}
+
+func (s *jsonStore) SetListRate(name string, rate Rate) error {
+	s.doc.Rates.Lists = setRate(s.doc.Rates.Lists, name, rate)
+	return s.file.Save(s.doc)
+}
+
+func (s *jsonStore) SetTagRate(tag string, rate Rate) error {
+	s.doc.Rates.Tags = setRate(s.doc.Rates.Tags, tag, rate)
+	return s.file.Save(s.doc)
+}
+
+// setRate sets or, for a zero rate, removes the rate for name.
+func setRate(rates map[string]Rate, name string, rate Rate) map[string]Rate {
+	if rate.IsZero() {
+		delete(rates, name)
+		return rates
+	}
+	if rates == nil {
+		rates = map[string]Rate{}
+	}
+	rates[name] = rate
+	return rates
+}
+
+func (s *jsonStore) ReadOnly() bool {
+	return s.file.readOnly
+}
+
+func (s *jsonStore) Close() error {
+	return s.file.Close()
+}
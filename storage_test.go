@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const version3Tasks = `{"version":3,"lists":[],"tasks":[{"id":"12345678-0000-4000-8000-000000000000","description":"a","status":"pending","created_at":"2024-01-01T10:00:00Z","sessions":[]}]}`
+
+// lockedByOther makes the lock on filename look held by a live process.
+func lockedByOther(t *testing.T, filename string) {
+	t.Helper()
+	if err := os.WriteFile(filename+".lock", []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTaskFileUpgradesOnlyWhenLocked(t *testing.T) {
+	for _, readOnly := range []bool{false, true} {
+		name := "lock holder"
+		if readOnly {
+			name = "read-only"
+		}
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "tasks.json")
+			if err := os.WriteFile(filename, []byte(version3Tasks), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if readOnly {
+				lockedByOther(t, filename)
+			}
+			f, err := openTaskFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if f.readOnly != readOnly {
+				t.Fatalf("readOnly = %v, want %v", f.readOnly, readOnly)
+			}
+
+			doc, err := f.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Version != currentSchemaVersion || len(doc.Tasks) != 1 {
+				t.Errorf("loaded version %d with %d tasks, want version %d with 1", doc.Version, len(doc.Tasks), currentSchemaVersion)
+			}
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, backupErr := os.Stat(filename + ".v3.bak")
+			if readOnly {
+				if !bytes.Equal(data, []byte(version3Tasks)) {
+					t.Error("read-only load rewrote the tasks file")
+				}
+				if !os.IsNotExist(backupErr) {
+					t.Error("read-only load wrote a backup")
+				}
+				return
+			}
+			if _, version, err := decodeTasksDocument(data); err != nil || version != currentSchemaVersion {
+				t.Errorf("file on disk is version %d (%v), want %d", version, err, currentSchemaVersion)
+			}
+			if backupErr != nil {
+				t.Errorf("no backup of the version 3 file: %v", backupErr)
+			}
+		})
+	}
+}
+
+// exitedPid returns the pid of a process that has already exited.
+func exitedPid(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquireLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		owner    func(t *testing.T) string // lock file contents, if any
+		wantHeld bool
+	}{
+		{"free", nil, false},
+		{"stale", func(t *testing.T) string { return strconv.Itoa(exitedPid(t)) }, false},
+		{"garbled", func(t *testing.T) string { return "not a pid" }, false},
+		{"live", func(t *testing.T) string { return strconv.Itoa(os.Getpid()) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "tasks.json")
+			if tt.owner != nil {
+				if err := os.WriteFile(filename+".lock", []byte(tt.owner(t)), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			lock, err := acquireLock(filename)
+			if tt.wantHeld {
+				if !errors.Is(err, errFileLocked) {
+					t.Fatalf("acquireLock() = %v, want %v", err, errFileLocked)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filename + ".lock")
+			if err != nil || string(data) != strconv.Itoa(os.Getpid()) {
+				t.Errorf("lock file holds %q (%v), want our pid", data, err)
+			}
+			if err := lock.release(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filename + ".lock"); !os.IsNotExist(err) {
+				t.Errorf("lock file left after release: %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenTaskFileReadOnlyWhileLocked(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tasks.json")
+	lockedByOther(t, filename)
+	f, err := openTaskFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.readOnly {
+		t.Fatal("opened a locked file for writing")
+	}
+	if _, err := f.Load(); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := f.Save(tasksDocument{}); !errors.Is(err, errReadOnly) {
+		t.Errorf("Save() = %v, want %v", err, errReadOnly)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename + ".lock"); err != nil {
+		t.Errorf("a read-only instance released the lock: %v", err)
+	}
+}
+
+func TestTaskFileSaveAfterExternalChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(filename string) error
+		wantErr error
+	}{
+		{"untouched", func(string) error { return nil }, nil},
+		{"touched", func(filename string) error {
+			later := time.Now().Add(time.Minute)
+			return os.Chtimes(filename, later, later)
+		}, nil},
+		{"edited", func(filename string) error {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(filename, bytes.Replace(data, []byte(`"a"`), []byte(`"b"`), 1), 0644)
+		}, errExternalChange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "tasks.json")
+			f, err := openTaskFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.Load(); err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			doc := tasksDocument{Tasks: []Task{{ID: uuid.New(), Description: "a", CreatedAt: time.Now()}}}
+			if err := f.Save(doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.change(filename); err != nil {
+				t.Fatal(err)
+			}
+			before, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc.Tasks[0].Description = "c"
+			if err := f.Save(doc); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() = %v, want %v", err, tt.wantErr)
+			}
+			after, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil && !bytes.Equal(before, after) {
+				t.Error("Save() overwrote the external change")
+			}
+			if tt.wantErr == nil && !bytes.Contains(after, []byte(`"c"`)) {
+				t.Error("Save() didn't write the change")
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "tasks.json")
+	if err := os.WriteFile(filename, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// A reader that opened the old file still sees all of it. Windows
+	// won't replace a file that is open, so the check is left out there.
+	var reader *os.File
+	if runtime.GOOS != "windows" {
+		var err error
+		if reader, err = os.Open(filename); err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+	}
+
+	if err := writeFileAtomic(filename, []byte("new contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if reader != nil {
+		if data, err := io.ReadAll(reader); err != nil || string(data) != "old" {
+			t.Errorf("open reader got %q (%v), want %q", data, err, "old")
+		}
+	}
+	if data, err := os.ReadFile(filename); err != nil || string(data) != "new contents" {
+		t.Errorf("file holds %q (%v), want %q", data, err, "new contents")
+	}
+	if info, err := os.Stat(filename); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0644) {
+		t.Errorf("file mode = %v (%v), want 0644", info.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+// normalizeTask makes tasks read back from different stores comparable:
+// times in UTC without monotonic readings, and empty slices as nil.
+func normalizeTask(task Task) Task {
+	for _, t := range []*time.Time{&task.LastStartedAt, &task.CreatedAt, &task.LastSavedAt, &task.Due, &task.CompletedAt} {
+		*t = t.UTC().Round(0)
+	}
+	sessions := task.Sessions
+	task.Sessions = nil
+	for _, s := range sessions {
+		task.Sessions = append(task.Sessions, Session{Start: s.Start.UTC().Round(0), End: s.End.UTC().Round(0), Note: s.Note})
+	}
+	if len(task.Tags) == 0 {
+		task.Tags = nil
+	}
+	if len(task.BlockedBy) == 0 {
+		task.BlockedBy = nil
+	}
+	return task
+}
+
+func normalizeTasks(tasks []Task) []Task {
+	normalized := make([]Task, len(tasks))
+	for i, task := range tasks {
+		normalized[i] = normalizeTask(task)
+	}
+	slices.SortFunc(normalized, func(a, b Task) int { return strings.Compare(a.ID.String(), b.ID.String()) })
+	return normalized
+}
+
+func TestStoresReadBackTheSame(t *testing.T) {
+	base := time.Date(2025, 10, 13, 9, 0, 0, 0, time.UTC)
+	parent := uuid.New()
+	tasks := []Task{
+		{
+			ID:            parent,
+			Description:   "parent",
+			Status:        InProgress,
+			LastStartedAt: base.Add(50 * time.Hour),
+			CreatedAt:     base,
+			LastSavedAt:   base.Add(51 * time.Hour),
+			List:          "Work",
+			Priority:      PriorityHigh,
+			Due:           base.AddDate(0, 0, 4),
+			Tags:          []string{"client", "urgent"},
+			Position:      3,
+			Recur:         Recurrence{Kind: recurWeekly, Weekday: time.Friday},
+			Notes:         "line one\nline two",
+			Rate:          Rate{Cents: 9550, Currency: "EUR"},
+			Sessions: []Session{
+				{Start: base.Add(-2 * time.Hour), End: base.Add(-time.Hour)},
+				{Start: base.Add(time.Hour), End: base.Add(90 * time.Minute), Note: "call"},
+				{Start: base.Add(48 * time.Hour), End: base.Add(49 * time.Hour)},
+			},
+		},
+		{
+			ID:          uuid.New(),
+			Description: "child",
+			Status:      Completed,
+			CreatedAt:   base.Add(time.Minute),
+			ParentID:    parent,
+			BlockedBy:   []uuid.UUID{parent},
+			CompletedAt: base.Add(3 * time.Hour),
+			Rate:        Rate{NotBillable: true},
+			Sessions:    []Session{{Start: base.Add(2 * time.Hour), End: base.Add(3 * time.Hour)}},
+		},
+		{ID: uuid.New(), Description: "untracked", Status: Pending, CreatedAt: base.Add(2 * time.Minute)},
+	}
+	rates := Rates{Lists: map[string]Rate{"Work": {Cents: 8000}}, Tags: map[string]Rate{"client": {Cents: 12000, Currency: "USD"}}}
+
+	dir := t.TempDir()
+	type result struct {
+		tasks, window []Task
+		lists         []string
+		rates         Rates
+	}
+	var results []result
+	for _, name := range []string{"tasks.json", "tasks.db"} {
+		store, err := openStore(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		if _, err := store.Load(); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		for _, task := range tasks {
+			if err := store.UpsertTask(task); err != nil {
+				t.Fatal(err)
+			}
+			for _, session := range task.Sessions {
+				if err := store.AppendSession(task.ID, session); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := store.AddList("Work"); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetListRate("Work", rates.Lists["Work"]); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetTagRate("client", rates.Tags["client"]); err != nil {
+			t.Fatal(err)
+		}
+
+		var r result
+		if r.tasks, err = store.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if r.window, err = store.Query(TaskQuery{From: base, To: base.Add(2*time.Hour + 30*time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		if r.lists, err = store.Lists(); err != nil {
+			t.Fatal(err)
+		}
+		if r.rates, err = store.Rates(); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, r)
+	}
+
+	want := normalizeTasks(cloneTasks(tasks))
+	for i := range want {
+		want[i].recalcTimeSpent()
+	}
+	for i, r := range results {
+		if got := normalizeTasks(r.tasks); !reflect.DeepEqual(got, want) {
+			t.Errorf("store %d Load():\n got %+v\nwant %+v", i, got, want)
+		}
+		if !slices.Equal(r.lists, []string{"Work"}) {
+			t.Errorf("store %d Lists() = %q", i, r.lists)
+		}
+		if !reflect.DeepEqual(r.rates, rates) {
+			t.Errorf("store %d Rates() = %+v, want %+v", i, r.rates, rates)
+		}
+	}
+
+	// The window catches the parent's second session and the child's
+	// session, which starts inside it and ends after it.
+	jsonWindow, sqliteWindow := normalizeTasks(results[0].window), normalizeTasks(results[1].window)
+	if !reflect.DeepEqual(jsonWindow, sqliteWindow) {
+		t.Errorf("Query() differs:\n json %+v\nsqlite %+v", jsonWindow, sqliteWindow)
+	}
+	if len(jsonWindow) != 2 {
+		t.Fatalf("Query() returned %d tasks, want 2", len(jsonWindow))
+	}
+	for _, task := range jsonWindow {
+		if len(task.Sessions) != 1 {
+			t.Errorf("Query() returned %q with %d sessions, want 1", task.Description, len(task.Sessions))
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+)
+
+// taskRow is one line of the task list: a task and how deeply it is nested
+// under its parents.
+type taskRow struct {
+	index int // into m.tasks
+	depth int
+}
+
+// visibleRows returns the rows of the current list in display order: each
+// task followed by its subtasks, unless it is collapsed. A task whose parent
+// is filtered out is shown at the top level.
+func (m model) visibleRows() []taskRow {
+	var shown []int
+	for i, task := range m.tasks {
+		if task.List == m.currentList() && (m.tagFilter == "" || task.hasTag(m.tagFilter)) && m.filter.matches(task) {
+			shown = append(shown, i)
+		}
+	}
+	sortTasks(m.tasks, shown, m.sortOrder, time.Now())
+
+	inView := make(map[uuid.UUID]bool, len(shown))
+	for _, i := range shown {
+		inView[m.tasks[i].ID] = true
+	}
+	children := map[uuid.UUID][]int{}
+	var roots []int
+	for _, i := range shown {
+		if parent := m.tasks[i].ParentID; parent != uuid.Nil && inView[parent] {
+			children[parent] = append(children[parent], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	var rows []taskRow
+	visited := make(map[int]bool, len(shown))
+	var walk func(i, depth int, hidden bool)
+	walk = func(i, depth int, hidden bool) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		if !hidden {
+			rows = append(rows, taskRow{index: i, depth: depth})
+		}
+		hidden = hidden || m.collapsed[m.tasks[i].ID]
+		for _, child := range children[m.tasks[i].ID] {
+			walk(child, depth+1, hidden)
+		}
+	}
+	for _, i := range roots {
+		walk(i, 0, false)
+	}
+	// Tasks only reachable through a parent cycle would otherwise vanish.
+	for _, i := range shown {
+		walk(i, 0, false)
+	}
+	return rows
+}
+
+// subtasks returns the indices of the tasks directly under the task at i.
+func (m model) subtasks(i int) []int {
+	return subtasksOf(m.tasks, i)
+}
+
+func subtasksOf(tasks []Task, i int) []int {
+	var children []int
+	for j, task := range tasks {
+		if task.ParentID == tasks[i].ID && j != i {
+			children = append(children, j)
+		}
+	}
+	return children
+}
+
+// descendants returns the indices of every task nested under the task at i.
+func (m model) descendants(i int) []int {
+	return descendantsOf(m.tasks, i)
+}
+
+func descendantsOf(tasks []Task, i int) []int {
+	var found []int
+	seen := map[int]bool{i: true}
+	queue := []int{i}
+	for len(queue) > 0 {
+		for _, child := range subtasksOf(tasks, queue[0]) {
+			if !seen[child] {
+				seen[child] = true
+				found = append(found, child)
+				queue = append(queue, child)
+			}
+		}
+		queue = queue[1:]
+	}
+	return found
+}
+
+// rolledUpTime is the time tracked on a task and everything under it.
+func (m model) rolledUpTime(i int, now time.Time) time.Duration {
+	total := m.tasks[i].elapsed(now)
+	for _, j := range m.descendants(i) {
+		total += m.tasks[j].elapsed(now)
+	}
+	return total
+}
+
+// completion counts the completed tasks among those nested under i.
+func (m model) completion(i int) (done, total int) {
+	for _, j := range m.descendants(i) {
+		total++
+		if m.tasks[j].Status == Completed {
+			done++
+		}
+	}
+	return done, total
+}
+
+// setCollapsed hides or shows the subtasks of the selected task. Collapsing
+// a task without subtasks selects its parent instead, as in a file tree.
+func (m *model) setCollapsed(collapsed bool) {
+	rows := m.visibleRows()
+	if m.cursor >= len(rows) {
+		return
+	}
+	i := rows[m.cursor].index
+	id := m.tasks[i].ID
+	if len(m.subtasks(i)) == 0 || (collapsed && m.collapsed[id]) {
+		if collapsed && rows[m.cursor].depth > 0 {
+			m.selectTask(m.tasks[i].ParentID)
+		}
+		return
+	}
+	if collapsed {
+		m.collapsed[id] = true
+	} else {
+		delete(m.collapsed, id)
+	}
+}
+
+// startSubtaskInput opens the add input for subtasks of the selected task.
+func (m *model) startSubtaskInput() tea.Cmd {
+	i, ok := m.selectedTask()
+	if !ok {
+		return nil
+	}
+	m.addParent = m.tasks[i].ID
+	delete(m.collapsed, m.addParent)
+	m.mode = modeAddTask
+	m.input.SetValue("")
+	m.input.Focus()
+	m.helpMsg = generateHelp(m.keyMap, modeAddTask)
+	return textinput.Blink
+}
+
+// deleteTask removes the task at i together with its subtasks.
+func (m *model) deleteTask(i int) {
+	doomed := map[uuid.UUID]bool{}
+	for _, j := range append(m.descendants(i), i) {
+		doomed[m.tasks[j].ID] = true
+		m.persist(m.store.DeleteTask(m.tasks[j].ID))
+	}
+	m.tasks = slices.DeleteFunc(m.tasks, func(task Task) bool { return doomed[task.ID] })
+}
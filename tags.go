@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// tagPattern matches an inline tag such as #backend. Tags start with a
+// letter so that issue references like #123 stay in the description.
+var tagPattern = regexp.MustCompile(`^#(\p{L}[\p{L}\p{N}_/-]*)$`)
+
+// parseTag returns the tag a word names, lower-cased, if it is one.
+func parseTag(word string) (string, bool) {
+	match := tagPattern.FindStringSubmatch(word)
+	if match == nil {
+		return "", false
+	}
+	return strings.ToLower(match[1]), true
+}
+
+// addTag appends tag to tags unless it is already there.
+func addTag(tags []string, tag string) []string {
+	if slices.Contains(tags, tag) {
+		return tags
+	}
+	return append(tags, tag)
+}
+
+func (t Task) hasTag(tag string) bool {
+	return slices.Contains(t.Tags, tag)
+}
+
+// formatTagsInput writes tags back in the inline syntax, for the edit input.
+func formatTagsInput(tags []string) string {
+	words := make([]string, len(tags))
+	for i, tag := range tags {
+		words[i] = "#" + tag
+	}
+	return strings.Join(words, " ")
+}
+
+// listTags returns the tags used in the current list, sorted.
+func (m model) listTags() []string {
+	var tags []string
+	for _, task := range m.tasks {
+		if task.List != m.currentList() {
+			continue
+		}
+		for _, tag := range task.Tags {
+			tags = addTag(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// cycleTagFilter steps the tag filter through the current list's tags and
+// back to showing every task.
+func (m *model) cycleTagFilter() {
+	tags := m.listTags()
+	next := 0
+	if i := slices.Index(tags, m.tagFilter); i >= 0 {
+		next = i + 1
+	}
+	if next < len(tags) {
+		m.tagFilter = tags[next]
+	} else {
+		m.tagFilter = ""
+	}
+	m.cursor = 0
+	m.viewport.SetYOffset(0)
+}
+
+// renderTagChips renders a task's tags as chips for its row.
+func renderTagChips(tags []string) string {
+	chips := make([]string, len(tags))
+	for i, tag := range tags {
+		chips[i] = tagChipStyle.Render("#" + tag)
+	}
+	return strings.Join(chips, " ")
+}
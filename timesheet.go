@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// timesheetFormat selects how `gotodo report` writes the timesheet.
+type timesheetFormat string
+
+const (
+	timesheetCSV      timesheetFormat = "csv"
+	timesheetMarkdown timesheetFormat = "md"
+)
+
+func parseTimesheetFormat(value string) (timesheetFormat, error) {
+	switch format := timesheetFormat(value); format {
+	case timesheetCSV, timesheetMarkdown:
+		return format, nil
+	default:
+		return "", fmt.Errorf(errorUnknownSheet, value)
+	}
+}
+
+// timesheetEntry is one tracked session on a timesheet.
+type timesheetEntry struct {
+	Task     Task
+	Session  Session
+	Duration time.Duration // rounded
+	Rate     Rate
+}
+
+// timesheet is the sessions tracked in a date range, oldest first.
+type timesheet struct {
+	Entries []timesheetEntry
+	Total   time.Duration
+	Amount  amounts
+}
+
+// newTimesheet lists the finished sessions that started from from up to
+// before to, leaving out tasks that aren't billed at rates if billableOnly
+// is set. Each duration is rounded to the nearest multiple of round, if set,
+// and the totals add up the rounded durations and what they bill.
+func newTimesheet(tasks []Task, rates Rates, from, to time.Time, round time.Duration, billableOnly bool) timesheet {
+	sheet := timesheet{Amount: amounts{}}
+	for _, task := range tasks {
+		rate := rates.rateFor(task)
+		if billableOnly && !rate.billable() {
+			continue
+		}
+		for _, session := range task.Sessions {
+			if session.Start.Before(from) || !session.Start.Before(to) {
+				continue
+			}
+			d := session.Duration()
+			if round > 0 {
+				d = d.Round(round)
+			}
+			sheet.Entries = append(sheet.Entries, timesheetEntry{Task: task, Session: session, Duration: d, Rate: rate})
+			sheet.Total += d
+			sheet.Amount.add(rate.Currency, rate.bill(d))
+		}
+	}
+	slices.SortFunc(sheet.Entries, func(a, b timesheetEntry) int { return a.Session.Start.Compare(b.Session.Start) })
+	return sheet
+}
+
+// rows returns the timesheet as table cells, header and total included.
+func (s timesheet) rows() [][]string {
+	rows := [][]string{strings.Split(timesheetHeader, ",")}
+	for _, entry := range s.Entries {
+		amount := ""
+		if entry.Rate.billable() {
+			amount = formatMoney(entry.Rate.bill(entry.Duration), entry.Rate.Currency)
+		}
+		rows = append(rows, []string{
+			entry.Session.Start.Format("2006-01-02"),
+			entry.Session.Start.Format("15:04"),
+			entry.Session.End.Format("15:04"),
+			formatDuration(entry.Duration),
+			entry.Task.Description,
+			formatTagsInput(entry.Task.Tags),
+			amount,
+		})
+	}
+	return append(rows, []string{totalsFooter, "", "", formatDuration(s.Total), "", "", s.Amount.String()})
+}
+
+func (s timesheet) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.WriteAll(s.rows())
+	return w.Error()
+}
+
+// writeMarkdown writes the timesheet as a Markdown table with the total in
+// bold.
+func (s timesheet) writeMarkdown(out io.Writer) error {
+	rows := s.rows()
+	widths := make([]int, len(rows[0]))
+	for r, row := range rows {
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(cell, "|", `\|`)
+			if r == len(rows)-1 && cell != "" {
+				row[i] = "**" + row[i] + "**"
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+	}
+	rule := make([]string, len(widths))
+	for i, width := range widths {
+		rule[i] = strings.Repeat("-", width)
+	}
+	rows = slices.Insert(rows, 1, rule)
+	for _, row := range rows {
+		if _, err := fmt.Fprintln(out, markdownRow(row, widths)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markdownRow(cells []string, widths []int) string {
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		padded[i] = cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+	}
+	return "| " + strings.Join(padded, " | ") + " |"
+}
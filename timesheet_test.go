@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewTimesheetRounding(t *testing.T) {
+	monday := day(2025, 10, 13)
+	session := func(start, length time.Duration) Session {
+		return Session{Start: monday.Add(start), End: monday.Add(start + length)}
+	}
+	tasks := []Task{
+		{Description: "billed", Rate: Rate{Cents: 6000}, Sessions: []Session{
+			session(9*time.Hour, 52*time.Minute),
+			session(11*time.Hour, 7*time.Minute+30*time.Second),
+			session(-time.Hour, time.Hour), // the Sunday before
+		}},
+		{Description: "unbilled", Sessions: []Session{
+			session(10*time.Hour, 7*time.Minute),
+			session(7*24*time.Hour, time.Hour), // the Monday after
+		}},
+	}
+	week := monday.AddDate(0, 0, 7)
+
+	tests := []struct {
+		name         string
+		round        time.Duration
+		billableOnly bool
+		want         []time.Duration
+		wantTotal    time.Duration
+		wantAmount   string
+	}{
+		{
+			name:       "unrounded",
+			want:       []time.Duration{52 * time.Minute, 7 * time.Minute, 7*time.Minute + 30*time.Second},
+			wantTotal:  66*time.Minute + 30*time.Second,
+			wantAmount: "59.50",
+		},
+		{
+			name:       "quarter hours",
+			round:      15 * time.Minute,
+			want:       []time.Duration{45 * time.Minute, 0, 15 * time.Minute},
+			wantTotal:  time.Hour,
+			wantAmount: "60.00",
+		},
+		{
+			name:         "billable only",
+			round:        6 * time.Minute,
+			billableOnly: true,
+			want:         []time.Duration{54 * time.Minute, 6 * time.Minute},
+			wantTotal:    time.Hour,
+			wantAmount:   "60.00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet := newTimesheet(tasks, Rates{}, monday, week, tt.round, tt.billableOnly)
+			if len(sheet.Entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(sheet.Entries), len(tt.want))
+			}
+			for i, entry := range sheet.Entries {
+				if entry.Duration != tt.want[i] {
+					t.Errorf("entry %d (%s at %s) = %s, want %s", i, entry.Task.Description, entry.Session.Start.Format("15:04"), entry.Duration, tt.want[i])
+				}
+				if i > 0 && entry.Session.Start.Before(sheet.Entries[i-1].Session.Start) {
+					t.Errorf("entry %d is out of order", i)
+				}
+			}
+			if sheet.Total != tt.wantTotal {
+				t.Errorf("total = %s, want %s", sheet.Total, tt.wantTotal)
+			}
+			if got := sheet.Amount.String(); got != tt.wantAmount {
+				t.Errorf("amount = %q, want %q", got, tt.wantAmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// undoLimit caps how many steps of history are kept for the session.
+const undoLimit = 100
+
+// checkpoint records the current tasks so the change about to be made can be
+// undone. Any redo history is dropped.
+func (m *model) checkpoint() {
+	m.undoStack = append(m.undoStack, cloneTasks(m.tasks))
+	if len(m.undoStack) > undoLimit {
+		m.undoStack = m.undoStack[1:]
+	}
+	m.redoStack = nil
+}
+
+func (m *model) undo() {
+	if len(m.undoStack) == 0 {
+		return
+	}
+	target := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+	m.redoStack = append(m.redoStack, cloneTasks(m.tasks))
+	m.restoreTasks(target)
+}
+
+func (m *model) redo() {
+	if len(m.redoStack) == 0 {
+		return
+	}
+	target := m.redoStack[len(m.redoStack)-1]
+	m.redoStack = m.redoStack[:len(m.redoStack)-1]
+	m.undoStack = append(m.undoStack, cloneTasks(m.tasks))
+	m.restoreTasks(target)
+}
+
+// restoreTasks brings the tasks back to a snapshot and persists the
+// difference. Tracked time is never undone: sessions recorded since the
+// snapshot are kept, a timer the snapshot has stopped is closed now, and a
+// timer it has running that has since been stopped starts again from now.
+// Resuming from an earlier point could count time already recorded on the
+// task started in its place, so the time in between is left untracked.
+func (m *model) restoreTasks(snapshot []Task) {
+	now := time.Now()
+	current := make(map[uuid.UUID]Task, len(m.tasks))
+	for _, task := range m.tasks {
+		current[task.ID] = task
+	}
+
+	restored := cloneTasks(snapshot)
+	kept := make(map[uuid.UUID]bool, len(restored))
+	for i := range restored {
+		task := &restored[i]
+		kept[task.ID] = true
+		old, exists := current[task.ID]
+		if !exists {
+			// Deleted since the snapshot: bring it back with its history.
+			m.persist(m.store.UpsertTask(*task))
+			for _, session := range task.Sessions {
+				m.persist(m.store.AppendSession(task.ID, session))
+			}
+			continue
+		}
+
+		task.Sessions = old.Sessions
+		switch {
+		case old.Status == InProgress && task.Status != InProgress:
+			if session, ok := old.stop(now); ok {
+				task.Sessions = old.Sessions
+				m.persist(m.store.AppendSession(task.ID, session))
+			}
+			task.LastStartedAt = time.Time{}
+		case old.Status == InProgress && task.Status == InProgress:
+			task.LastStartedAt = old.LastStartedAt
+		case task.Status == InProgress:
+			task.LastStartedAt = now
+		}
+		task.recalcTimeSpent()
+		if !reflect.DeepEqual(*task, old) {
+			m.persist(m.store.UpsertTask(*task))
+		}
+	}
+
+	for id := range current {
+		if !kept[id] {
+			m.persist(m.store.DeleteTask(id))
+		}
+	}
+	m.tasks = restored
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Undoing "start B", which paused A, keeps B's session and starts A again
+// from now rather than from where its last session ended, so the time
+// tracked on B isn't also counted on A.
+func TestUndoStartResumesFromNow(t *testing.T) {
+	m := newTestModel(t)
+	now := time.Now()
+	a := Task{ID: uuid.New(), Description: "A", Status: InProgress, CreatedAt: now.Add(-time.Hour), LastStartedAt: now.Add(-time.Hour)}
+	b := Task{ID: uuid.New(), Description: "B", Status: Pending, CreatedAt: now.Add(-time.Hour)}
+	m.tasks = []Task{a, b}
+	m.checkpoint()
+
+	// What starting B does: A is paused and B runs for ten minutes.
+	m.tasks[0].stop(now.Add(-10 * time.Minute))
+	m.tasks[0].Status = Paused
+	m.tasks[1].start(now.Add(-10 * time.Minute))
+	m.tasks[1].stop(now)
+	m.tasks[1].Status = Paused
+
+	m.undo()
+	if m.tasks[0].Status != InProgress || m.tasks[0].LastStartedAt.Before(now) {
+		t.Errorf("A is %v since %v, want running since %v or later", m.tasks[0].Status, m.tasks[0].LastStartedAt, now)
+	}
+	if len(m.tasks[1].Sessions) != 1 {
+		t.Errorf("B has %d sessions, want its 1 kept", len(m.tasks[1].Sessions))
+	}
+	tracked := m.tasks[0].elapsed(now) + m.tasks[1].elapsed(now)
+	if tracked > time.Hour+time.Second {
+		t.Errorf("%s tracked in the hour since A started", tracked)
+	}
+}